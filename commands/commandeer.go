@@ -61,6 +61,9 @@ type commandeer struct {
 	// Used in cases where we get flooded with events in server mode.
 	debounce func(f func())
 
+	// The pages rendered on request by the server, see renderDynamic.
+	renderCache *renderCache
+
 	serverPorts         []int
 	languagesConfigured bool
 	languages           helpers.Languages
@@ -49,6 +49,9 @@ func (b *commandsBuilder) addAll() *commandsBuilder {
 		newListCmd(),
 		newImportCmd(),
 		newGenCmd(),
+		b.newStoreCmd(),
+		b.newIngestCmd(),
+		b.newUpdateCmd(),
 		createReleaser(),
 	)
 
@@ -245,6 +248,11 @@ func (cc *hugoBuilderCommon) handleFlags(cmd *cobra.Command) {
 	cmd.Flags().Int32("printEachProgress", 0, "No not reset database and redis")
 	cmd.Flags().StringP("rocketDbDir", "", "/Users/davidmz/dev/clone-army/test-db", "filesystem path to themes directory")
 	cmd.Flags().Int32("renderThreads", 1, "No not reset database and redis")
+	cmd.Flags().StringP("storeCompression", "", "", "RocksDB block compression: none, snappy, zlib, lz4, lz4hc or zstd (default RocksDB's, snappy)")
+	cmd.Flags().BoolP("storeCompressBlobs", "", false, "store page content gzipped in the page store")
+	cmd.Flags().BoolP("storeMigrate", "", false, "migrate a page store kept with --noReset to the current schema")
+	cmd.Flags().Int("ingestWriters", 0, "number of page store writers when reading content (default number of CPUs)")
+	cmd.Flags().Int("ingestBatchSize", 500, "number of pages per bulk insert when reading content")
 
 	// Set bash-completion.
 	// Each flag must first be defined before using the SetAnnotation() call.
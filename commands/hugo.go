@@ -219,6 +219,11 @@ func initializeFlags(cmd *cobra.Command, cfg config.Provider) {
 		"gzip",
 		"noMongoIndex",
 		"renderThreads",
+		"storeCompression",
+		"storeCompressBlobs",
+		"storeMigrate",
+		"ingestWriters",
+		"ingestBatchSize",
 	}
 
 	for _, key := range persFlagKeys {
@@ -708,6 +713,7 @@ func (c *commandeer) buildSites() (err error) {
 
 func (c *commandeer) rebuildSites(events []fsnotify.Event) error {
 	defer c.timeTrack(time.Now(), "Total")
+	defer c.renderCache.purge()
 
 	if err := c.initSites(); err != nil {
 		return err
@@ -735,8 +741,11 @@ func (c *commandeer) fullRebuild() {
 		jww.ERROR.Println("Failed to reload config:", err)
 	} else if err := c.recreateAndBuildSites(true); err != nil {
 		jww.ERROR.Println(err)
-	} else if !c.h.buildWatch && !c.Cfg.GetBool("disableLiveReload") {
-		livereload.ForceRefresh()
+	} else {
+		c.renderCache.purge()
+		if !c.h.buildWatch && !c.Cfg.GetBool("disableLiveReload") {
+			livereload.ForceRefresh()
+		}
 	}
 }
 
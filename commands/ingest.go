@@ -0,0 +1,83 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"sort"
+
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var _ cmder = (*ingestCmd)(nil)
+
+type ingestCmd struct {
+	*baseBuilderCmd
+}
+
+func (b *commandsBuilder) newIngestCmd() *ingestCmd {
+	cmd := &cobra.Command{
+		Use:   "ingest [source]...",
+		Short: "Load the configured ingest sources into the page store",
+		Long: `Load the pages of the JSONL, CSV and Mongo sources configured under
+ingest into the page store, without building the site. Pages loaded before
+from the same records are replaced.
+
+Without arguments all sources are loaded, otherwise the named ones.`,
+	}
+
+	c := &ingestCmd{baseBuilderCmd: b.newBuilderCmd(cmd)}
+
+	cmd.RunE = c.ingest
+
+	return c
+}
+
+func (c *ingestCmd) ingest(cmd *cobra.Command, args []string) error {
+	cfgInit := func(c *commandeer) error {
+		// Keep the pages of the other sources.
+		c.Set("noReset", true)
+		return nil
+	}
+
+	comm, err := initializeConfig(false, &c.hugoBuilderCommon, c, cfgInit)
+	if err != nil {
+		return err
+	}
+
+	sites, err := hugolib.NewHugoSites(*comm.DepsCfg)
+
+	if err != nil {
+		return newSystemError("Error creating sites", err)
+	}
+
+	counts, err := sites.Ingest(args...)
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		jww.FEEDBACK.Printf("%-24s %10d pages\n", name, counts[name])
+	}
+
+	if err != nil {
+		return newSystemError("Error ingesting", err)
+	}
+
+	return nil
+}
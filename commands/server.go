@@ -53,6 +53,7 @@ type serverCmd struct {
 	noHTTPCache       bool
 
 	disableFastRender bool
+	renderDynamic     bool
 
 	*baseBuilderCmd
 }
@@ -93,6 +94,7 @@ of a second, you will be able to save and see your changes nearly instantly.`,
 	cc.cmd.Flags().BoolVar(&cc.navigateToChanged, "navigateToChanged", false, "navigate to changed content file on live browser reload")
 	cc.cmd.Flags().BoolVar(&cc.renderToDisk, "renderToDisk", false, "render to Destination path (default is render to memory & serve from there)")
 	cc.cmd.Flags().BoolVar(&cc.disableFastRender, "disableFastRender", false, "enables full re-renders on changes")
+	cc.cmd.Flags().BoolVar(&cc.renderDynamic, "renderDynamic", false, "render pages when requested instead of up front")
 
 	cc.cmd.Flags().String("memstats", "", "log memory usage to this file")
 	cc.cmd.Flags().String("meminterval", "100ms", "interval to poll memory usage (requires --memstats), valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\".")
@@ -142,6 +144,9 @@ func (s *serverCmd) server(cmd *cobra.Command, args []string) error {
 		if cmd.Flags().Changed("disableFastRender") {
 			c.Set("disableFastRender", s.disableFastRender)
 		}
+		if cmd.Flags().Changed("renderDynamic") {
+			c.Set("renderDynamic", s.renderDynamic)
+		}
 		if s.serverWatch {
 			c.Set("watch", true)
 		}
@@ -335,9 +340,9 @@ func (f *fileServer) createEndpoint(i int) (*http.ServeMux, string, string, erro
 	mu := http.NewServeMux()
 
 	if u.Path == "" || u.Path == "/" {
-		mu.Handle("/", fileserver)
+		mu.Handle("/", f.dynamicHandler(fileserver))
 	} else {
-		mu.Handle(u.Path, http.StripPrefix(u.Path, fileserver))
+		mu.Handle(u.Path, f.dynamicHandler(http.StripPrefix(u.Path, fileserver)))
 	}
 
 	endpoint := net.JoinHostPort(f.s.serverInterface, strconv.Itoa(port))
@@ -378,6 +383,11 @@ func (c *commandeer) serve(s *serverCmd) error {
 		livereload.Initialize()
 	}
 
+	if c.Cfg.GetBool("renderDynamic") {
+		c.renderCache = newRenderCache(c.Cfg.GetInt("renderDynamicCacheSize"))
+		jww.FEEDBACK.Println("Rendering pages on request")
+	}
+
 	var sigs = make(chan os.Signal)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
@@ -388,6 +398,10 @@ func (c *commandeer) serve(s *serverCmd) error {
 			mu.HandleFunc("/livereload.js", livereload.ServeJS)
 			mu.HandleFunc("/livereload", livereload.Handler)
 		}
+
+		if updateEndpoint := c.Cfg.GetString("updateEndpoint"); updateEndpoint != "" {
+			mu.Handle(updateEndpoint, srv.updateHandler(doLiveReload))
+		}
 		jww.FEEDBACK.Printf("Web Server is available at %s (bind address %s)\n", serverURL, s.serverInterface)
 		go func() {
 			err = http.ListenAndServe(endpoint, mu)
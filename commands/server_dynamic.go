@@ -0,0 +1,161 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+
+	"github.com/gohugoio/hugo/hugolib"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+const defaultRenderCacheSize = 1000
+
+// renderCache is an LRU of the pages rendered on request in the dynamic
+// render mode. It is purged on every rebuild, so template changes show up
+// right away.
+type renderCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+
+	// Counts the purges, so that a page rendered before one is not added
+	// after it, see add.
+	gen uint64
+}
+
+type renderCacheEntry struct {
+	path string
+	page *hugolib.RenderedPage
+}
+
+func newRenderCache(size int) *renderCache {
+	if size <= 0 {
+		size = defaultRenderCacheSize
+	}
+	return &renderCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *renderCache) get(path string) (*hugolib.RenderedPage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, found := c.items[path]
+	if !found {
+		return nil, false
+	}
+
+	c.ll.MoveToFront(e)
+
+	return e.Value.(*renderCacheEntry).page, true
+}
+
+// generation returns the number of purges so far, to pass to add for a
+// page about to be rendered.
+func (c *renderCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gen
+}
+
+// add adds a page rendered in the given generation, unless the cache was
+// purged since.
+func (c *renderCache) add(path string, page *hugolib.RenderedPage, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	if e, found := c.items[path]; found {
+		c.ll.MoveToFront(e)
+		e.Value.(*renderCacheEntry).page = page
+		return
+	}
+
+	c.items[path] = c.ll.PushFront(&renderCacheEntry{path: path, page: page})
+
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*renderCacheEntry).path)
+	}
+}
+
+func (c *renderCache) purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// dynamicHandler renders the pages requested through h on request when
+// renderDynamic is set, and passes everything else, e.g. static files, on
+// to h.
+func (f *fileServer) dynamicHandler(h http.Handler) http.Handler {
+	if !f.c.Cfg.GetBool("renderDynamic") {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		path := r.URL.Path
+
+		page, found := f.c.renderCache.get(path)
+		if !found {
+			gen := f.c.renderCache.generation()
+
+			var err error
+			page, err = f.c.hugo.RenderPath(path)
+			if err != nil {
+				jww.ERROR.Printf("Failed to render %q: %s\n", path, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if page == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+			f.c.renderCache.add(path, page, gen)
+		}
+
+		if page.Redirect != "" {
+			http.Redirect(w, r, page.Redirect, http.StatusMovedPermanently)
+			return
+		}
+
+		if f.s.noHTTPCache {
+			w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
+			w.Header().Set("Pragma", "no-cache")
+		}
+
+		w.Header().Set("Content-Type", page.MediaType+"; charset=utf-8")
+		w.Write(page.Content)
+	})
+}
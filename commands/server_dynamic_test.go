@@ -0,0 +1,66 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderCache(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	c := newRenderCache(2)
+
+	a := &hugolib.RenderedPage{Content: []byte("a")}
+	b := &hugolib.RenderedPage{Content: []byte("b")}
+
+	c.add("/a/", a, 0)
+	c.add("/b/", b, 0)
+
+	p, found := c.get("/a/")
+	assert.True(found)
+	assert.Equal(a, p)
+
+	// /b/ is now the least recently used.
+	c.add("/c/", &hugolib.RenderedPage{}, 0)
+
+	_, found = c.get("/b/")
+	assert.False(found)
+	_, found = c.get("/a/")
+	assert.True(found)
+	_, found = c.get("/c/")
+	assert.True(found)
+
+	gen := c.generation()
+	c.purge()
+
+	_, found = c.get("/a/")
+	assert.False(found)
+
+	// A page rendered before the purge is stale.
+	c.add("/a/", a, gen)
+	_, found = c.get("/a/")
+	assert.False(found)
+
+	c.add("/a/", a, c.generation())
+	_, found = c.get("/a/")
+	assert.True(found)
+
+	var nilCache *renderCache
+	nilCache.purge()
+}
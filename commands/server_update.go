@@ -0,0 +1,85 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/gohugoio/hugo/livereload"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// The largest push the update endpoint reads.
+const maxUpdateBodySize = 32 << 20
+
+// updateHandler takes changed records of an ingest source at the
+// updateEndpoint, the same as hugo update, e.g.
+//
+//	curl -H "Authorization: Bearer $TOKEN" --data-binary @prices.jsonl \
+//	  "http://localhost:1313/_update?source=products"
+//
+// Requests need the updateToken as a bearer token if one is set.
+func (f *fileServer) updateHandler(liveReload bool) http.Handler {
+	token := f.c.Cfg.GetString("updateToken")
+
+	if token == "" && f.s.serverInterface != "127.0.0.1" && f.s.serverInterface != "localhost" {
+		jww.WARN.Printf("The update endpoint is open to anyone who can reach %s, set an updateToken\n", f.s.serverInterface)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		source := r.URL.Query().Get("source")
+		if source == "" {
+			http.Error(w, "missing source", http.StatusBadRequest)
+			return
+		}
+
+		records, err := hugolib.DecodeUpdateRecords(http.MaxBytesReader(w, r.Body, maxUpdateBodySize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := f.c.hugo.Update(source, records)
+		if err != nil {
+			jww.ERROR.Printf("Error updating from %q: %s\n", source, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jww.FEEDBACK.Printf("Updated %d pages from %q, rendered %d pages\n", result.Pages, source, result.Rendered)
+
+		f.c.renderCache.purge()
+
+		if liveReload {
+			livereload.ForceRefresh()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+}
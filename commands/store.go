@@ -0,0 +1,111 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"strconv"
+
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var _ cmder = (*storeCmd)(nil)
+
+type storeCmd struct {
+	*baseCmd
+}
+
+func (b *commandsBuilder) newStoreCmd() *storeCmd {
+	cc := &storeCmd{}
+
+	cc.baseCmd = newBaseCmd(&cobra.Command{
+		Use:   "store",
+		Short: "Inspect and maintain the page store",
+		Long: `Inspect and maintain the Mongo and RocksDB page store.
+
+Store requires a subcommand, e.g. ` + "`hugo store stats`.",
+		RunE: nil,
+	})
+
+	cc.cmd.AddCommand(b.newStoreStatsCmd().getCommand())
+
+	return cc
+}
+
+type storeStatsCmd struct {
+	*baseBuilderCmd
+}
+
+func (b *commandsBuilder) newStoreStatsCmd() *storeStatsCmd {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Print disk usage of the page store",
+		Long: `Print the disk usage of the page store collections and the RocksDB
+store, with the savings from storeCompression and storeCompressBlobs.`,
+	}
+
+	c := &storeStatsCmd{baseBuilderCmd: b.newBuilderCmd(cmd)}
+
+	cmd.RunE = c.stats
+
+	return c
+}
+
+func (c *storeStatsCmd) stats(cmd *cobra.Command, args []string) error {
+	cfgInit := func(c *commandeer) error {
+		// Never wipe the store we are about to inspect.
+		c.Set("noReset", true)
+		return nil
+	}
+
+	comm, err := initializeConfig(false, &c.hugoBuilderCommon, c, cfgInit)
+	if err != nil {
+		return err
+	}
+
+	sites, err := hugolib.NewHugoSites(*comm.DepsCfg)
+
+	if err != nil {
+		return newSystemError("Error creating sites", err)
+	}
+
+	stats := sites.Sites[0].PageStore.Stats()
+
+	jww.FEEDBACK.Printf("%-16s %10s %12s %12s %12s %12s %8s\n", "collection", "docs", "size", "storage", "blobs raw", "blobs", "saved")
+
+	for _, cs := range stats.Collections {
+		jww.FEEDBACK.Printf("%-16s %10d %12s %12s %12s %12s %8s\n",
+			cs.Name, cs.Count, formatStoreSize(cs.Size), formatStoreSize(cs.StorageSize),
+			formatStoreSize(cs.BlobRawSize), formatStoreSize(cs.BlobSize), formatStoreSavings(cs.BlobRawSize, cs.BlobSize))
+	}
+
+	jww.FEEDBACK.Println()
+	jww.FEEDBACK.Printf("rocksdb (%s): %d keys, %s raw, %s on disk, %s saved\n",
+		stats.RocksDb.Compression, stats.RocksDb.Keys, formatStoreSize(stats.RocksDb.RawSize),
+		formatStoreSize(stats.RocksDb.SstSize), formatStoreSavings(stats.RocksDb.RawSize, stats.RocksDb.SstSize))
+
+	return nil
+}
+
+func formatStoreSize(n int64) string {
+	return strconv.FormatFloat(float64(n)/1024/1024, 'f', 1, 64) + "MB"
+}
+
+func formatStoreSavings(raw, stored int64) string {
+	if raw == 0 || stored == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(100-float64(stored)*100/float64(raw), 'f', 1, 64) + "%"
+}
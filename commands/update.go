@@ -0,0 +1,97 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"io"
+	"os"
+
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var _ cmder = (*updateCmd)(nil)
+
+type updateCmd struct {
+	*baseBuilderCmd
+}
+
+func (b *commandsBuilder) newUpdateCmd() *updateCmd {
+	cmd := &cobra.Command{
+		Use:   "update source [file]",
+		Short: "Update pages in the page store from changed records",
+		Long: `Update pages from changed records of an ingest source, read as JSON
+from the file or stdin, in the page store of the last build, and render them
+and the listings they are in to the publish dir.
+
+The records are a JSON array or one JSON object per line. hugo server takes
+the same records at its updateEndpoint.`,
+		Args: cobra.RangeArgs(1, 2),
+	}
+
+	c := &updateCmd{baseBuilderCmd: b.newBuilderCmd(cmd)}
+
+	cmd.RunE = c.update
+
+	return c
+}
+
+func (c *updateCmd) update(cmd *cobra.Command, args []string) error {
+	var in io.Reader = os.Stdin
+
+	if len(args) > 1 {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return newUserError(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	records, err := hugolib.DecodeUpdateRecords(in)
+	if err != nil {
+		return newUserError(err)
+	}
+
+	cfgInit := func(c *commandeer) error {
+		// Update the store of the last build.
+		c.Set("noReset", true)
+		return nil
+	}
+
+	comm, err := initializeConfig(false, &c.hugoBuilderCommon, c, cfgInit)
+	if err != nil {
+		return err
+	}
+
+	sites, err := hugolib.NewHugoSites(*comm.DepsCfg)
+
+	if err != nil {
+		return newSystemError("Error creating sites", err)
+	}
+
+	if err := sites.LoadFromStore(); err != nil {
+		return newSystemError("Error loading sites", err)
+	}
+
+	result, err := sites.Update(args[0], records)
+	if err != nil {
+		return newSystemError("Error updating", err)
+	}
+
+	jww.FEEDBACK.Printf("Updated %d pages, rendered %d pages\n", result.Pages, result.Rendered)
+
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+)
+
+const (
+	canonicalPagersSelf  = "self"
+	canonicalPagersFirst = "first"
+)
+
+// CanonicalConfig configures the canonical URLs of generated pages, e.g.
+//
+//	[canonical]
+//	pagers = "first"
+//	facetDepth = 1
+//	variants = true
+//
+// Pages that are not their own canonical are left out of the sitemap.
+type CanonicalConfig struct {
+	// What pagers after the first point to, "self" (the default) or
+	// "first", the first page of the listing.
+	Pagers string
+
+	// Facet combination pages with more terms point to the combination of
+	// their first facetDepth terms. 0, the default, leaves them all as
+	// their own canonical.
+	FacetDepth int
+
+	// Whether variants point to their master, see VariantsConfig.
+	Variants bool
+}
+
+func decodeCanonicalConfig(in interface{}) (CanonicalConfig, error) {
+	c := CanonicalConfig{Pagers: canonicalPagersSelf}
+
+	if in == nil {
+		return c, nil
+	}
+
+	if err := mapstructure.WeakDecode(cast.ToStringMap(in), &c); err != nil {
+		return c, fmt.Errorf("failed to decode canonical: %s", err)
+	}
+
+	switch c.Pagers {
+	case "":
+		c.Pagers = canonicalPagersSelf
+	case canonicalPagersSelf, canonicalPagersFirst:
+	default:
+		return c, fmt.Errorf("canonical pagers must be %q or %q, got %q", canonicalPagersSelf, canonicalPagersFirst, c.Pagers)
+	}
+
+	if c.FacetDepth < 0 {
+		return c, fmt.Errorf("canonical facetDepth must not be negative, got %d", c.FacetDepth)
+	}
+
+	return c, nil
+}
+
+// facetCombination returns the combination c points to, and false if c is
+// its own canonical.
+func (c CanonicalConfig) facetCombination(fc FacetCombination) (FacetCombination, bool) {
+	if c.FacetDepth == 0 || len(fc.Terms) <= c.FacetDepth {
+		return fc, false
+	}
+	return FacetCombination{Section: fc.Section, Terms: fc.Terms[:c.FacetDepth]}, true
+}
+
+// Canonical returns the absolute URL search engines should index in place
+// of this page, which is its permalink unless CanonicalConfig says
+// otherwise.
+//
+//	<link rel="canonical" href="{{ .Canonical }}">
+func (p *Page) Canonical() string {
+	if p.headless {
+		return ""
+	}
+
+	if p.canonicalURL != "" {
+		return p.s.PathSpec.AbsURL(p.canonicalURL, false)
+	}
+
+	if p.s.canonical.Variants && !p.IsMasterVariant() {
+		return p.MasterVariant().Permalink()
+	}
+
+	return p.Permalink()
+}
+
+// Canonical returns the canonical URL of this page, or of this pager.
+func (p *PageOutput) Canonical() string {
+	if p.headless {
+		return ""
+	}
+
+	if p.canonicalURL == "" && p.paginator != nil && p.paginator.number > 1 && p.s.canonical.Pagers == canonicalPagersSelf {
+		return p.s.PathSpec.AbsURL(string(p.paginator.URL()), false)
+	}
+
+	return p.Page.Canonical()
+}
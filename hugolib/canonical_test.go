@@ -0,0 +1,96 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCanonicalConfig(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	c, err := decodeCanonicalConfig(nil)
+	assert.NoError(err)
+	assert.Equal(CanonicalConfig{Pagers: canonicalPagersSelf}, c)
+
+	c, err = decodeCanonicalConfig(map[string]interface{}{"pagers": "first", "facetDepth": "1"})
+	assert.NoError(err)
+	assert.Equal(CanonicalConfig{Pagers: canonicalPagersFirst, FacetDepth: 1}, c)
+
+	_, err = decodeCanonicalConfig(map[string]interface{}{"pagers": "last"})
+	assert.Error(err)
+	_, err = decodeCanonicalConfig(map[string]interface{}{"facetDepth": -1})
+	assert.Error(err)
+}
+
+func TestCanonicalFacetCombination(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	brand := FacetTerm{Taxonomy: "brands", Key: "lenovo"}
+	price := FacetTerm{Taxonomy: "prices", Key: "1000-2000"}
+
+	single := FacetCombination{Section: "laptops", Terms: []FacetTerm{brand}}
+	pair := FacetCombination{Section: "laptops", Terms: []FacetTerm{brand, price}}
+
+	_, ok := CanonicalConfig{}.facetCombination(pair)
+	assert.False(ok)
+
+	c := CanonicalConfig{FacetDepth: 1}
+
+	_, ok = c.facetCombination(single)
+	assert.False(ok)
+
+	canonical, ok := c.facetCombination(pair)
+	assert.True(ok)
+	assert.Equal(single, canonical)
+}
+
+func TestCanonicalBuild(t *testing.T) {
+	t.Parallel()
+
+	templates := []string{
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `{{ $pager := .Paginate .Data.Pages }}Canonical: {{ .Canonical }}|`,
+	}
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", "paginate = 1\n"+facetTestConfig)
+	b.WithContent(facetTestContent...)
+	b.WithTemplates(templates...)
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/index.html", "Canonical: http://example.com/products/|")
+	b.AssertFileContent("public/products/page/2/index.html", "Canonical: http://example.com/products/page/2/|")
+
+	b = newTestSitesBuilder(t)
+	b.WithConfigFile("toml", "paginate = 1\n"+facetTestConfig+`
+[canonical]
+pagers = "first"
+facetDepth = 1
+
+[facetCombinations]
+sections = ["products"]
+`)
+	b.WithContent(facetTestContent...)
+	b.WithTemplates(templates...)
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/page/2/index.html", "Canonical: http://example.com/products/|")
+	b.AssertFileContent("public/products/brand-acme/index.html", "Canonical: http://example.com/products/brand-acme/|")
+	b.AssertFileContent("public/products/brand-acme/color-red/index.html", "Canonical: http://example.com/products/brand-acme/|")
+}
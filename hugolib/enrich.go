@@ -0,0 +1,356 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+)
+
+const ingestFormatJSON = "json"
+
+// How the values joined from a data file combine with the front matter.
+const (
+	// The data file wins.
+	enrichMergeOverride = "override"
+
+	// The front matter wins, the data file only fills in missing params.
+	enrichMergeKeep = "keep"
+
+	// Like override, but maps in both are merged key by key.
+	enrichMergeDeep = "merge"
+)
+
+// EnrichSourceConfig joins the pages to the rows of a data file by a key
+// param, adding the fields of the matching row to the page params, e.g.
+//
+//	[[enrich]]
+//	source = "data/prices.csv"
+//	key = "page_human_id"
+//	field = "id"
+//	numbers = ["price", "stock"]
+//
+// Pages are enriched as they are read, so everything built from the stored
+// params, e.g. where, taxonomies, lite pages and facets, sees the joined
+// values. Only params are set; title, date etc. come from the front matter.
+type EnrichSourceConfig struct {
+	// Used in errors. Defaults to the source.
+	Name string
+
+	// The CSV, JSON or JSONL file, relative to the working dir. A JSON file
+	// holds an array of rows or an object of rows by join value.
+	Source string
+
+	// "csv", "json" or "jsonl". Defaults to the extension of the source.
+	Format string
+
+	// The field separator of a CSV source. Defaults to ",".
+	Delimiter string
+
+	// The page param to join on.
+	Key string
+
+	// The field of the rows to join on. Defaults to the key.
+	Field string
+
+	// The fields copied to the params. Defaults to all but the join field.
+	Fields []string
+
+	// Fields parsed as numbers, e.g. prices in a CSV source.
+	Numbers []string
+
+	// "override" (the default), "keep" or "merge".
+	Merge string
+}
+
+// decodeEnrichConfig reads the enrich sources of a site.
+func decodeEnrichConfig(in interface{}) ([]EnrichSourceConfig, error) {
+	if in == nil {
+		return nil, nil
+	}
+
+	items, err := cast.ToSliceE(in)
+	if err != nil {
+		return nil, fmt.Errorf("enrich must be a list: %s", err)
+	}
+
+	sources := make([]EnrichSourceConfig, 0, len(items))
+
+	for _, item := range items {
+		var c EnrichSourceConfig
+		if err := mapstructure.WeakDecode(cast.ToStringMap(item), &c); err != nil {
+			return nil, fmt.Errorf("failed to decode enrich source: %s", err)
+		}
+
+		if c.Source == "" {
+			return nil, fmt.Errorf("enrich source needs a source file")
+		}
+
+		if c.Name == "" {
+			c.Name = c.Source
+		}
+
+		if c.Format == "" {
+			c.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(c.Source)), ".")
+		}
+
+		switch c.Format {
+		case ingestFormatCSV, ingestFormatJSON, ingestFormatJSONL:
+		default:
+			return nil, fmt.Errorf("enrich format must be %q, %q or %q, got %q", ingestFormatCSV, ingestFormatJSON, ingestFormatJSONL, c.Format)
+		}
+
+		c.Key = strings.ToLower(c.Key)
+		if c.Key == "" {
+			return nil, fmt.Errorf("enrich source %q needs a key", c.Name)
+		}
+
+		if c.Field == "" {
+			c.Field = c.Key
+		}
+
+		switch c.Merge {
+		case "":
+			c.Merge = enrichMergeOverride
+		case enrichMergeOverride, enrichMergeKeep, enrichMergeDeep:
+		default:
+			return nil, fmt.Errorf("enrich merge must be %q, %q or %q, got %q", enrichMergeOverride, enrichMergeKeep, enrichMergeDeep, c.Merge)
+		}
+
+		sources = append(sources, c)
+	}
+
+	return sources, nil
+}
+
+// enrichTable is the rows of an enrich source by join value, holding the
+// params to set.
+type enrichTable struct {
+	c    EnrichSourceConfig
+	rows map[string]map[string]interface{}
+}
+
+// add adds a row of the source, returning false if there is one with the
+// same join value already.
+func (t *enrichTable) add(row map[string]interface{}) bool {
+	key := cast.ToString(normalizeIngestValue(row[t.c.Field]))
+	if key == "" {
+		return true
+	}
+
+	if _, found := t.rows[key]; found {
+		return false
+	}
+
+	params := make(map[string]interface{})
+
+	copyField := func(field string) {
+		v, found := row[field]
+		if !found || v == nil || v == "" {
+			// Empty cells leave the front matter alone.
+			return
+		}
+		params[strings.ToLower(field)] = normalizeIngestValue(v)
+	}
+
+	if len(t.c.Fields) == 0 {
+		for field := range row {
+			if field != t.c.Field {
+				copyField(field)
+			}
+		}
+	} else {
+		for _, field := range t.c.Fields {
+			copyField(field)
+		}
+	}
+
+	for _, field := range t.c.Numbers {
+		if s, ok := params[strings.ToLower(field)].(string); ok {
+			params[strings.ToLower(field)] = parseIngestNumber(s)
+		}
+	}
+
+	t.rows[key] = params
+
+	return true
+}
+
+// apply sets the params joined to p, if any.
+func (t *enrichTable) apply(p *Page) {
+	key, ok := p.lookupParam(t.c.Key)
+	if !ok {
+		return
+	}
+
+	if params, found := t.rows[key]; found {
+		mergeEnrichParams(p.params, params, t.c.Merge)
+	}
+}
+
+func mergeEnrichParams(dst, src map[string]interface{}, merge string) {
+	for k, v := range src {
+		existing, found := dst[k]
+
+		switch {
+		case !found:
+			dst[k] = v
+		case merge == enrichMergeKeep:
+		case merge == enrichMergeDeep:
+			dm, ok1 := existing.(map[string]interface{})
+			sm, ok2 := v.(map[string]interface{})
+			if ok1 && ok2 {
+				mergeEnrichParams(dm, sm, merge)
+			} else {
+				dst[k] = v
+			}
+		default:
+			dst[k] = v
+		}
+	}
+}
+
+// pageEnricher applies the enrich sources of a site to its pages.
+type pageEnricher struct {
+	tables []*enrichTable
+}
+
+// newPageEnricher reads the enrich sources of s. It returns nil if there
+// are none.
+func (s *Site) newPageEnricher() (*pageEnricher, error) {
+	if len(s.enrichSources) == 0 {
+		return nil, nil
+	}
+
+	e := &pageEnricher{}
+
+	for _, c := range s.enrichSources {
+		t, err := s.readEnrichTable(c)
+		if err != nil {
+			return nil, err
+		}
+		s.Log.INFO.Printf("Read %d rows to enrich pages from %q", len(t.rows), c.Name)
+		e.tables = append(e.tables, t)
+	}
+
+	return e, nil
+}
+
+func (s *Site) readEnrichTable(c EnrichSourceConfig) (*enrichTable, error) {
+	t := &enrichTable{c: c, rows: make(map[string]map[string]interface{})}
+
+	duplicate := func(row map[string]interface{}) {
+		s.Log.WARN.Printf("enrich source %q has more than one row with %s %v, the first one is used", c.Name, c.Field, row[c.Field])
+	}
+
+	if c.Format == ingestFormatJSON {
+		rows, err := readEnrichJSON(s.PathSpec.AbsPathify(c.Source), c.Field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read enrich source %q: %s", c.Name, err)
+		}
+		for _, row := range rows {
+			if !t.add(row) {
+				duplicate(row)
+			}
+		}
+		return t, nil
+	}
+
+	records, err := s.PageStore.openIngestSource(IngestSourceConfig{Name: c.Name, Source: c.Source, Format: c.Format, Delimiter: c.Delimiter})
+	if err != nil {
+		return nil, err
+	}
+	defer records.Close()
+
+	for {
+		row, err := records.Next()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read row %d of enrich source %q: %s", len(t.rows)+1, c.Name, err)
+		}
+		if !t.add(row) {
+			duplicate(row)
+		}
+	}
+}
+
+// readEnrichJSON reads a JSON array of rows, or an object of rows by join
+// value, which is then set as the field of rows without it.
+func readEnrichJSON(filename, field string) ([]map[string]interface{}, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return enrichJSONRows(v, field)
+}
+
+func enrichJSONRows(v interface{}, field string) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+
+	switch vv := v.(type) {
+	case []interface{}:
+		for i, e := range vv {
+			row, ok := e.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("row %d is not an object", i+1)
+			}
+			rows = append(rows, row)
+		}
+	case map[string]interface{}:
+		for key, e := range vv {
+			row, ok := e.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("row %q is not an object", key)
+			}
+			if _, found := row[field]; !found {
+				row[field] = key
+			}
+			rows = append(rows, row)
+		}
+	default:
+		return nil, fmt.Errorf("must hold an array or an object of rows")
+	}
+
+	return rows, nil
+}
+
+// enrich sets the params joined to p by the enrich sources.
+func (e *pageEnricher) enrich(p *Page) {
+	if e == nil {
+		return
+	}
+
+	for _, t := range e.tables {
+		t.apply(p)
+	}
+}
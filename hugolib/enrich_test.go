@@ -0,0 +1,202 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeEnrichConfig(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	sources, err := decodeEnrichConfig(nil)
+	assert.NoError(err)
+	assert.Len(sources, 0)
+
+	sources, err = decodeEnrichConfig([]interface{}{
+		map[string]interface{}{"source": "data/prices.csv", "key": "Page_Human_Id", "field": "id"},
+		map[string]interface{}{"source": "data/reviews.json", "key": "sku", "merge": "keep"},
+	})
+	assert.NoError(err)
+	assert.Len(sources, 2)
+	assert.Equal("csv", sources[0].Format)
+	assert.Equal("page_human_id", sources[0].Key)
+	assert.Equal("id", sources[0].Field)
+	assert.Equal(enrichMergeOverride, sources[0].Merge)
+	assert.Equal("json", sources[1].Format)
+	assert.Equal("sku", sources[1].Field)
+	assert.Equal(enrichMergeKeep, sources[1].Merge)
+
+	for _, in := range []interface{}{
+		"prices",
+		[]interface{}{map[string]interface{}{"key": "sku"}},
+		[]interface{}{map[string]interface{}{"source": "data/prices.xml", "key": "sku"}},
+		[]interface{}{map[string]interface{}{"source": "data/prices.csv"}},
+		[]interface{}{map[string]interface{}{"source": "data/prices.csv", "key": "sku", "merge": "replace"}},
+	} {
+		_, err := decodeEnrichConfig(in)
+		assert.Error(err, "%v", in)
+	}
+}
+
+func TestEnrichTable(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	newTable := func(merge string) *enrichTable {
+		c := EnrichSourceConfig{Key: "page_human_id", Field: "id", Numbers: []string{"Price"}, Merge: merge}
+		tbl := &enrichTable{c: c, rows: make(map[string]map[string]interface{})}
+		assert.True(tbl.add(map[string]interface{}{"id": "1", "Price": "9.99", "stock": "", "rating": map[string]interface{}{"count": json.Number("12")}}))
+		assert.False(tbl.add(map[string]interface{}{"id": "1", "Price": "1"}))
+		assert.True(tbl.add(map[string]interface{}{"id": "", "Price": "1"}))
+		return tbl
+	}
+
+	newPage := func() *Page {
+		return &Page{params: map[string]interface{}{
+			"page_human_id": int64(1),
+			"price":         5,
+			"stock":         3,
+			"rating":        map[string]interface{}{"count": 2, "average": 4.5},
+		}}
+	}
+
+	tbl := newTable(enrichMergeOverride)
+	assert.Len(tbl.rows, 1)
+
+	p := newPage()
+	tbl.apply(p)
+	assert.Equal(9.99, p.params["price"])
+	assert.Equal(3, p.params["stock"])
+	assert.Equal(map[string]interface{}{"count": int64(12)}, p.params["rating"])
+
+	p = newPage()
+	newTable(enrichMergeKeep).apply(p)
+	assert.Equal(5, p.params["price"])
+
+	p = newPage()
+	newTable(enrichMergeDeep).apply(p)
+	assert.Equal(9.99, p.params["price"])
+	assert.Equal(map[string]interface{}{"count": int64(12), "average": 4.5}, p.params["rating"])
+
+	p = &Page{params: map[string]interface{}{"page_human_id": "2"}}
+	tbl.apply(p)
+	assert.Len(p.params, 1)
+}
+
+func TestEnrichJSONRows(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	rows, err := enrichJSONRows([]interface{}{map[string]interface{}{"sku": "a"}}, "sku")
+	assert.NoError(err)
+	assert.Equal([]map[string]interface{}{{"sku": "a"}}, rows)
+
+	rows, err = enrichJSONRows(map[string]interface{}{"a": map[string]interface{}{"price": 1}}, "sku")
+	assert.NoError(err)
+	assert.Equal([]map[string]interface{}{{"sku": "a", "price": 1}}, rows)
+
+	_, err = enrichJSONRows([]interface{}{"a"}, "sku")
+	assert.Error(err)
+
+	_, err = enrichJSONRows("a", "sku")
+	assert.Error(err)
+}
+
+func TestEnrichBuild(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "hugo-enrich")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "prices.csv")
+	assert.NoError(ioutil.WriteFile(source, []byte("id,price\np1,9.5\np2,12\n"), 0644))
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", fmt.Sprintf(`
+baseURL = "http://example.com/"
+
+[[enrich]]
+source = %q
+key = "page_human_id"
+field = "id"
+numbers = ["price"]
+`, source))
+	b.WithContent(
+		"products/p1.md", "---\ntitle: P1\npage_human_id: p1\n---\n",
+		"products/p2.md", "---\ntitle: P2\npage_human_id: p2\nprice: 10\n---\n",
+		"products/p3.md", "---\ntitle: P3\npage_human_id: p3\n---\n",
+	)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}|Price: {{ .Params.price }}|Lite: {{ (.Site.GetLitePageByPageHumanId .Params.page_human_id).Price }}|`,
+		"_default/list.html", `{{ .Title }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/p1/index.html", "Price: 9.5|", "Lite: 9.5|")
+	// The data file wins by default.
+	b.AssertFileContent("public/products/p2/index.html", "Price: 12|", "Lite: 12|")
+	b.AssertFileContent("public/products/p3/index.html", "Price: |", "Lite: 0|")
+}
+
+func TestEnrichCSVLitePagesBuild(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "hugo-enrich")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// Every value of a CSV source is a string.
+	source := filepath.Join(dir, "products.csv")
+	assert.NoError(ioutil.WriteFile(source, []byte(`id,image,stars_class,master_variation,tags
+p1,/img/p1.png,stars-4,true,cotton summer
+p2,,,false,
+`), 0644))
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", fmt.Sprintf(`
+baseURL = "http://example.com/"
+
+[[enrich]]
+source = %q
+key = "page_human_id"
+field = "id"
+`, source))
+	b.WithContent(
+		"products/p1.md", "---\ntitle: P1\npage_human_id: p1\n---\n",
+		"products/p2.md", "---\ntitle: P2\npage_human_id: p2\nstars_class: stars-2\n---\n",
+	)
+	b.WithTemplates(
+		"_default/single.html", `{{ with .Site.GetLitePageByPageHumanId .Params.page_human_id }}{{ .Title }}:{{ .Image }}:{{ .StarsClass }}:{{ .MasterVariation }}:{{ .Tags }}|{{ end }}`,
+		"_default/list.html", `{{ .Title }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/p1/index.html", "P1:/img/p1.png:stars-4:true:[cotton summer]|")
+	// Empty cells leave the front matter alone.
+	b.AssertFileContent("public/products/p2/index.html", "P2::stars-2:false:[]|")
+}
@@ -0,0 +1,374 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gohugoio/hugo/helpers"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+)
+
+// FacetCombinationsConfig configures the generated facet combination
+// pages, e.g.
+//
+//	[facetCombinations]
+//	sections = ["laptops"]
+//	maxCardinality = 2
+//	minPages = 5
+//
+// which, with brands and prices facets, gives pages such as
+// /laptops/brand-lenovo/ and /laptops/brand-lenovo/price-1000-2000/.
+type FacetCombinationsConfig struct {
+	// The root sections to generate pages for. Defaults to all.
+	Sections []string
+
+	// The maximum number of terms combined.
+	MaxCardinality int
+
+	// Combinations with fewer pages are skipped.
+	MinPages int
+}
+
+// decodeFacetCombinationsConfig reads the facetCombinations config. It
+// returns nil if there is none, i.e. no pages are generated.
+func decodeFacetCombinationsConfig(in interface{}) (*FacetCombinationsConfig, error) {
+	if in == nil {
+		return nil, nil
+	}
+
+	c := &FacetCombinationsConfig{MaxCardinality: 2, MinPages: 1}
+
+	if err := mapstructure.WeakDecode(cast.ToStringMap(in), c); err != nil {
+		return nil, fmt.Errorf("failed to decode facetCombinations: %s", err)
+	}
+
+	if c.MaxCardinality < 1 {
+		return nil, fmt.Errorf("facetCombinations maxCardinality must be at least 1, got %d", c.MaxCardinality)
+	}
+
+	if c.MinPages < 1 {
+		c.MinPages = 1
+	}
+
+	return c, nil
+}
+
+// A FacetCombination is a root section refined on terms of one or more
+// facets, at most one term per facet, in facet order.
+type FacetCombination struct {
+	Section string
+	Terms   []FacetTerm
+}
+
+// FacetTerm is a term of a facet taxonomy.
+type FacetTerm struct {
+	Taxonomy string
+	Key      string
+}
+
+// segments returns the path segments of the combination below its section,
+// one "singular-key" per term.
+func (c FacetCombination) segments(pluralSingular map[string]string) []string {
+	segments := make([]string, len(c.Terms))
+	for i, t := range c.Terms {
+		segments[i] = pluralSingular[t.Taxonomy] + "-" + t.Key
+	}
+	return segments
+}
+
+func (c FacetCombination) path(pluralSingular map[string]string) string {
+	return path.Join(append([]string{c.Section}, c.segments(pluralSingular)...)...)
+}
+
+// with returns the combination refined on one more term, in facet order. It
+// returns false if the combination already has a term of that facet.
+func (c FacetCombination) with(t FacetTerm, facets FacetsConfig) (FacetCombination, bool) {
+	order := make(map[string]int, len(facets))
+	for i, f := range facets {
+		order[f.Taxonomy] = i
+	}
+
+	if _, found := order[t.Taxonomy]; !found {
+		return c, false
+	}
+
+	terms := make([]FacetTerm, 0, len(c.Terms)+1)
+	for _, ct := range c.Terms {
+		if ct.Taxonomy == t.Taxonomy {
+			return c, false
+		}
+		terms = append(terms, ct)
+	}
+	terms = append(terms, t)
+
+	sort.SliceStable(terms, func(i, j int) bool {
+		return order[terms[i].Taxonomy] < order[terms[j].Taxonomy]
+	})
+
+	return FacetCombination{Section: c.Section, Terms: terms}, true
+}
+
+// facetCombinations returns the combinations of up to max of the terms,
+// at most one term per facet. The terms must be in facet order.
+func facetCombinations(terms []FacetTerm, max int) [][]FacetTerm {
+	var combinations [][]FacetTerm
+
+	var walk func(from int, current []FacetTerm)
+	walk = func(from int, current []FacetTerm) {
+		for i := from; i < len(terms); i++ {
+			if len(current) > 0 && current[len(current)-1].Taxonomy == terms[i].Taxonomy {
+				continue
+			}
+
+			combination := append(append([]FacetTerm{}, current...), terms[i])
+			combinations = append(combinations, combination)
+
+			if len(combination) < max {
+				walk(i+1, combination)
+			}
+		}
+	}
+
+	walk(0, nil)
+
+	return combinations
+}
+
+// without returns the combination without the term.
+func (c FacetCombination) without(t FacetTerm) FacetCombination {
+	terms := make([]FacetTerm, 0, len(c.Terms))
+	for _, ct := range c.Terms {
+		if ct != t {
+			terms = append(terms, ct)
+		}
+	}
+	return FacetCombination{Section: c.Section, Terms: terms}
+}
+
+// sortFacetTerms sorts terms in facet order, then by key.
+func sortFacetTerms(terms []FacetTerm, facets FacetsConfig) {
+	order := make(map[string]int, len(facets))
+	for i, f := range facets {
+		order[f.Taxonomy] = i
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Taxonomy != terms[j].Taxonomy {
+			return order[terms[i].Taxonomy] < order[terms[j].Taxonomy]
+		}
+		return terms[i].Key < terms[j].Key
+	})
+}
+
+// newFacetCombinationPage creates the list page of a combination. It is a
+// taxonomy page below its section, so it renders with the taxonomy layouts
+// of the section type, e.g. laptops/taxonomy.html.
+func (s *Site) newFacetCombinationPage(c FacetCombination) *Page {
+	p := s.newNodePage(KindTaxonomy, append([]string{c.Section}, c.segments(s.taxonomiesPluralSingular)...)...)
+	p.facetCombination = &c
+
+	title := []string{s.titleFunc(c.Section)}
+	for _, t := range c.Terms {
+		title = append(title, strings.Replace(s.titleFunc(t.Key), "-", " ", -1))
+	}
+	p.title = strings.Join(title, " ")
+
+	return p
+}
+
+// facetCombinationPageId returns the id of the page of a combination.
+func (s *Site) facetCombinationPageId(c FacetCombination) string {
+	return generatePageId(KindTaxonomy, append([]string{c.Section}, c.segments(s.taxonomiesPluralSingular)...)...)
+}
+
+// assembleFacetCombinations creates the pages of the facet combinations with
+// enough pages, see also ThinPagesRule. Pages that exist already are left
+// alone.
+func (s *Site) assembleFacetCombinations(existing map[string]bool) []*Page {
+	config := s.facetCombinations
+	if len(s.facets) == 0 || config == nil {
+		return nil
+	}
+
+	sections := config.Sections
+	if len(sections) == 0 {
+		sections = s.PageStore.weightedPagesSections()
+	}
+
+	var pages []*Page
+
+	for _, section := range sections {
+		for _, cc := range s.PageStore.countFacetCombinations(section, s.facets, *config) {
+			id := s.facetCombinationPageId(cc.FacetCombination)
+			if existing[id] {
+				continue
+			}
+
+			thinAction := s.thinPages.action("", section, cc.Count)
+			if thinAction == thinActionSkip {
+				continue
+			}
+
+			existing[id] = true
+
+			p := s.newFacetCombinationPage(cc.FacetCombination)
+			p.thinAction = thinAction
+			if canonical, ok := s.canonical.facetCombination(cc.FacetCombination); ok {
+				p.canonicalURL = s.facetCombinationRelURL(canonical)
+			}
+			pages = append(pages, p)
+		}
+	}
+
+	return pages
+}
+
+// facetCombinationURL returns the relative URL of the page of the
+// combination, or of its section if it has no terms. It returns false if
+// there is no such page.
+func (s *Site) facetCombinationURL(c FacetCombination) (string, bool) {
+	if len(c.Terms) > 0 {
+		if s.facetCombinations == nil || len(c.Terms) > s.facetCombinations.MaxCardinality {
+			return "", false
+		}
+		if !s.PageStore.pageExists(PageId(s.facetCombinationPageId(c))) {
+			return "", false
+		}
+	}
+
+	return s.facetCombinationRelURL(c), true
+}
+
+func (s *Site) facetCombinationRelURL(c FacetCombination) string {
+	return s.PathSpec.RelURL(helpers.SanitizeURLKeepTrailingSlash(s.PathSpec.URLize("/"+c.path(s.taxonomiesPluralSingular)+"/")), true)
+}
+
+func (ps *PageStore) weightedPagesSections() []string {
+	var sections []string
+	if err := ps.MongoSession.DB("hugo").C("weighted_pages").Find(nil).Distinct("section", &sections); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	sort.Strings(sections)
+
+	return sections
+}
+
+// facetCombinationCount is a facet combination with its number of pages.
+type facetCombinationCount struct {
+	FacetCombination
+	Count int
+}
+
+// countFacetCombinations returns the combinations of the facet terms of the
+// pages in the section that have at least config.MinPages pages.
+func (ps *PageStore) countFacetCombinations(section string, facets FacetsConfig, config FacetCombinationsConfig) []facetCombinationCount {
+	pipe := []bson.M{
+		{"$match": bson.M{"section": section, "plural": bson.M{"$in": facets.taxonomies()}}},
+		{"$group": bson.M{"_id": "$pageid", "terms": bson.M{"$push": bson.M{"taxonomy": "$plural", "key": "$key"}}}},
+	}
+
+	items := ps.MongoSession.DB("hugo").C("weighted_pages").Pipe(pipe).AllowDiskUse().Batch(1000).Iter()
+
+	counts := make(map[string]int)
+	combinations := make(map[string][]FacetTerm)
+
+	var item struct {
+		Terms []FacetTerm
+	}
+
+	for items.Next(&item) {
+		sortFacetTerms(item.Terms, facets)
+
+		for _, terms := range facetCombinations(item.Terms, config.MaxCardinality) {
+			key := fmt.Sprint(terms)
+			if _, found := combinations[key]; !found {
+				combinations[key] = terms
+			}
+			counts[key]++
+		}
+
+		item.Terms = nil
+	}
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key, count := range counts {
+		if count >= config.MinPages {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := make([]facetCombinationCount, len(keys))
+	for i, key := range keys {
+		result[i] = facetCombinationCount{
+			FacetCombination: FacetCombination{Section: section, Terms: combinations[key]},
+			Count:            counts[key],
+		}
+	}
+
+	return result
+}
+
+// facetCombinationPageIds returns the ids of the pages in the combination,
+// in the order of a taxonomy page.
+func (ps *PageStore) facetCombinationPageIds(c FacetCombination) PageIds {
+	or := make([]bson.M, len(c.Terms))
+	for i, t := range c.Terms {
+		or[i] = bson.M{"plural": t.Taxonomy, "key": t.Key}
+	}
+
+	pipe := []bson.M{
+		{"$match": bson.M{"section": c.Section, "$or": or}},
+		{"$group": bson.M{
+			"_id":    "$pageid",
+			"count":  bson.M{"$sum": 1},
+			"weight": bson.M{"$min": "$weight"},
+			"date":   bson.M{"$first": "$date"},
+			"title":  bson.M{"$first": "$title"},
+		}},
+		{"$match": bson.M{"count": len(c.Terms)}},
+		{"$sort": sortDocument(weightedPagesSort)},
+		{"$project": bson.M{"_id": 1}},
+	}
+
+	items := ps.MongoSession.DB("hugo").C("weighted_pages").Pipe(pipe).AllowDiskUse().Batch(1000).Iter()
+
+	pageIds := make(PageIds, 0)
+
+	item := WeightedPagePipe{}
+	for items.Next(&item) {
+		pageIds = append(pageIds, PageId(item.ID))
+	}
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return pageIds
+}
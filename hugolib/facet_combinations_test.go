@@ -0,0 +1,113 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFacetCombinations(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	facets := FacetsConfig{{Taxonomy: "brands"}, {Taxonomy: "prices"}, {Taxonomy: "colors"}}
+
+	terms := []FacetTerm{
+		{Taxonomy: "colors", Key: "red"},
+		{Taxonomy: "prices", Key: "1000-2000"},
+		{Taxonomy: "colors", Key: "black"},
+		{Taxonomy: "brands", Key: "lenovo"},
+	}
+	sortFacetTerms(terms, facets)
+	assert.Equal([]FacetTerm{
+		{Taxonomy: "brands", Key: "lenovo"},
+		{Taxonomy: "prices", Key: "1000-2000"},
+		{Taxonomy: "colors", Key: "black"},
+		{Taxonomy: "colors", Key: "red"},
+	}, terms)
+
+	assert.Len(facetCombinations(terms, 1), 4)
+
+	// 4 singles, 5 pairs and 2 triples; never two colors together.
+	combinations := facetCombinations(terms, 3)
+	assert.Len(combinations, 11)
+	for _, c := range combinations {
+		seen := make(map[string]bool)
+		for _, t := range c {
+			assert.False(seen[t.Taxonomy], "%v", c)
+			seen[t.Taxonomy] = true
+		}
+	}
+
+	pluralSingular := map[string]string{"brands": "brand", "prices": "price", "colors": "color"}
+
+	c := FacetCombination{Section: "laptops", Terms: []FacetTerm{{Taxonomy: "prices", Key: "1000-2000"}}}
+	assert.Equal("laptops/price-1000-2000", c.path(pluralSingular))
+
+	refined, ok := c.with(FacetTerm{Taxonomy: "brands", Key: "lenovo"}, facets)
+	assert.True(ok)
+	assert.Equal("laptops/brand-lenovo/price-1000-2000", refined.path(pluralSingular))
+
+	_, ok = refined.with(FacetTerm{Taxonomy: "brands", Key: "dell"}, facets)
+	assert.False(ok)
+	_, ok = refined.with(FacetTerm{Taxonomy: "sizes", Key: "15"}, facets)
+	assert.False(ok)
+
+	assert.Equal(c, refined.without(FacetTerm{Taxonomy: "brands", Key: "lenovo"}))
+}
+
+func TestDecodeFacetCombinationsConfig(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	c, err := decodeFacetCombinationsConfig(nil)
+	assert.NoError(err)
+	assert.Nil(c)
+
+	c, err = decodeFacetCombinationsConfig(map[string]interface{}{"sections": []string{"laptops"}, "minPages": "5"})
+	assert.NoError(err)
+	assert.Equal(&FacetCombinationsConfig{Sections: []string{"laptops"}, MaxCardinality: 2, MinPages: 5}, c)
+
+	_, err = decodeFacetCombinationsConfig(map[string]interface{}{"maxCardinality": 0})
+	assert.Error(err)
+}
+
+func TestFacetCombinationPagesBuild(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", facetTestConfig+`
+[facetCombinations]
+sections = ["products"]
+maxCardinality = 2
+`)
+	b.WithContent(facetTestContent...)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `Pages: {{ range .Data.Pages.ByWeight }}{{ .Title }} {{ end }}|`+
+			`{{ range .Facets }}{{ .Title }}:{{ range .Values }} {{ .Key }} {{ .URL }}{{ end }}|{{ end }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/index.html", "brands: acme /products/brand-acme/ zeta /products/brand-zeta/|")
+	b.AssertFileContent("public/products/brand-acme/index.html",
+		"Pages: P1 P2 |",
+		"brands: acme /products/|",
+		"colors: blue /products/brand-acme/color-blue/ red /products/brand-acme/color-red/|")
+	b.AssertFileContent("public/products/brand-acme/color-red/index.html", "Pages: P1 |")
+	b.AssertFileContent("public/products/color-red/index.html", "Pages: P1 P3 |")
+}
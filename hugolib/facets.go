@@ -0,0 +1,356 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gohugoio/hugo/helpers"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+)
+
+const (
+	facetOrderCount = "count"
+	facetOrderKey   = "key"
+)
+
+// facetPageIdsBatchSize is how many page ids are matched per query when the
+// facets of a listing are counted from its page ids.
+const facetPageIdsBatchSize = 5000
+
+// FacetConfig configures one facet of the faceted navigation, e.g.
+//
+//	[[facets]]
+//	taxonomy = "brands"
+//	label = "facetBrands"
+//	order = "count"
+//	limit = 10
+//	weight = 1
+type FacetConfig struct {
+	// The taxonomy, by its plural name, the facet refines on.
+	Taxonomy string
+
+	// The i18n id of the facet title. Defaults to the taxonomy.
+	Label string
+
+	// How the values are ordered, "count" (most pages first, the default)
+	// or "key".
+	Order string
+
+	// The maximum number of values shown, 0 for all of them.
+	Limit int
+
+	// Facets are ordered by weight, then as configured.
+	Weight int
+}
+
+// FacetsConfig is the facets of a site, in order.
+type FacetsConfig []FacetConfig
+
+// decodeFacetsConfig reads the facets config. Only taxonomies, given as
+// plural by singular, can be facets.
+func decodeFacetsConfig(in interface{}, taxonomies map[string]string) (FacetsConfig, error) {
+	if in == nil {
+		return nil, nil
+	}
+
+	items, err := cast.ToSliceE(in)
+	if err != nil {
+		return nil, fmt.Errorf("facets must be a list: %s", err)
+	}
+
+	plurals := make(map[string]bool)
+	for _, plural := range taxonomies {
+		plurals[plural] = true
+	}
+
+	var facets FacetsConfig
+
+	for _, item := range items {
+		var fc FacetConfig
+		if err := mapstructure.WeakDecode(cast.ToStringMap(item), &fc); err != nil {
+			return nil, fmt.Errorf("failed to decode facet: %s", err)
+		}
+
+		if !plurals[fc.Taxonomy] {
+			return nil, fmt.Errorf("facet taxonomy %q is not a configured taxonomy", fc.Taxonomy)
+		}
+
+		if fc.Label == "" {
+			fc.Label = fc.Taxonomy
+		}
+
+		switch fc.Order {
+		case "":
+			fc.Order = facetOrderCount
+		case facetOrderCount, facetOrderKey:
+		default:
+			return nil, fmt.Errorf("facet order must be %q or %q, got %q", facetOrderCount, facetOrderKey, fc.Order)
+		}
+
+		if fc.Limit < 0 {
+			return nil, fmt.Errorf("facet limit must not be negative, got %d", fc.Limit)
+		}
+
+		facets = append(facets, fc)
+	}
+
+	sort.SliceStable(facets, func(i, j int) bool {
+		return facets[i].Weight < facets[j].Weight
+	})
+
+	return facets, nil
+}
+
+func (fc FacetsConfig) taxonomies() []string {
+	plurals := make([]string, len(fc))
+	for i, f := range fc {
+		plurals[i] = f.Taxonomy
+	}
+	return plurals
+}
+
+// A Facet is the refinements of a listing page on one taxonomy.
+type Facet struct {
+	FacetConfig
+
+	// The translated label.
+	Title string
+
+	Values []FacetValue
+
+	// The number of values left out because of the limit.
+	More int
+}
+
+// A FacetValue is a refinement of a listing page on a taxonomy term.
+type FacetValue struct {
+	Key string
+
+	// The number of pages of the listing with the term.
+	Count int
+
+	// The URL of the listing refined on the term.
+	URL string
+
+	// Whether the listing is already refined on the term.
+	Active bool
+}
+
+// Facets returns the available refinements of the listing page p, per
+// configured facet. Facets without values are left out.
+//
+//	{{ range .Facets }}
+//	<h4>{{ .Title }}</h4>
+//	{{ range .Values }}<a href="{{ .URL }}">{{ .Key }} ({{ .Count }})</a>{{ end }}
+//	{{ end }}
+//
+// They are worked out once per page.
+func (p *Page) Facets() []Facet {
+	p.facetsInit.Do(func() {
+		p.facets = p.buildFacets()
+	})
+	return p.facets
+}
+
+func (p *Page) buildFacets() []Facet {
+	s := p.s
+
+	if len(s.facets) == 0 || !p.listsPages() {
+		return nil
+	}
+
+	counts := s.PageStore.facetCounts(p, s.facets.taxonomies())
+
+	// Refinements link to the facet combination pages where there are
+	// any, see FacetCombinationsConfig.
+	var (
+		combination    FacetCombination
+		hasCombination bool
+	)
+
+	active := make(map[FacetTerm]bool)
+
+	switch {
+	case p.facetCombination != nil:
+		combination, hasCombination = *p.facetCombination, true
+		for _, t := range combination.Terms {
+			active[t] = true
+		}
+	case p.Kind == KindSection && len(p.sections) == 1:
+		combination, hasCombination = FacetCombination{Section: p.sections[0]}, true
+	case p.Kind == KindTaxonomy:
+		if plural, term, ok := taxonomyPluralTerm(p); ok {
+			active[FacetTerm{Taxonomy: plural, Key: s.getTaxonomyKey(term)}] = true
+		}
+	}
+
+	facets := make([]Facet, 0, len(s.facets))
+
+	for _, fc := range s.facets {
+		values := counts[fc.Taxonomy]
+		if len(values) == 0 {
+			continue
+		}
+
+		sortFacetValues(values, fc.Order)
+
+		f := Facet{FacetConfig: fc, Title: s.translate(fc.Label)}
+
+		if fc.Limit > 0 && len(values) > fc.Limit {
+			f.More = len(values) - fc.Limit
+			values = values[:fc.Limit]
+		}
+
+		for i := range values {
+			t := FacetTerm{Taxonomy: fc.Taxonomy, Key: values[i].Key}
+			values[i].Active = active[t]
+			values[i].URL = s.facetValueURL(combination, hasCombination, t, values[i].Active)
+		}
+
+		f.Values = values
+		facets = append(facets, f)
+	}
+
+	return facets
+}
+
+func sortFacetValues(values []FacetValue, order string) {
+	sort.SliceStable(values, func(i, j int) bool {
+		if order == facetOrderCount && values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Key < values[j].Key
+	})
+}
+
+// translate returns the translation of the i18n id, or the id when there
+// is none.
+func (s *Site) translate(id string) string {
+	if s.Deps == nil || s.Translate == nil {
+		return id
+	}
+
+	if t := s.Translate(id); t != "" {
+		return t
+	}
+
+	return id
+}
+
+// facetValueURL returns the URL of a refinement on t: the facet combination
+// with t added, or removed if it is active, or else the list page of t.
+func (s *Site) facetValueURL(c FacetCombination, hasCombination bool, t FacetTerm, active bool) string {
+	if hasCombination {
+		if active {
+			if u, ok := s.facetCombinationURL(c.without(t)); ok {
+				return u
+			}
+		} else if refined, ok := c.with(t, s.facets); ok {
+			if u, ok := s.facetCombinationURL(refined); ok {
+				return u
+			}
+		}
+	}
+
+	return s.taxonomyTermURL(t.Taxonomy, t.Key)
+}
+
+// taxonomyTermURL returns the relative URL of the list page of a term.
+func (s *Site) taxonomyTermURL(plural, key string) string {
+	return s.PathSpec.RelURL(helpers.SanitizeURLKeepTrailingSlash(s.PathSpec.URLize("/"+plural+"/"+key+"/")), true)
+}
+
+// facetCounts counts the pages listed on p per term of the taxonomies, as
+// read from the weighted pages. The counts are keyed by taxonomy.
+func (ps *PageStore) facetCounts(p *Page, plurals []string) map[string][]FacetValue {
+	wp := ps.MongoSession.DB("hugo").C("weighted_pages")
+
+	group := bson.M{"$group": bson.M{
+		"_id":   bson.M{"plural": "$plural", "key": "$key"},
+		"count": bson.M{"$sum": 1},
+	}}
+
+	var pipes [][]bson.M
+
+	switch {
+	case p.Kind == KindHome:
+		pipes = append(pipes, []bson.M{{"$match": bson.M{"lang": p.Lang(), "plural": bson.M{"$in": plurals}}}, group})
+	case p.Kind == KindTaxonomy && p.facetCombination == nil:
+		// The pages of a term are themselves in the weighted pages, so
+		// this is a self join that never leaves the store.
+		plural, term, ok := taxonomyPluralTerm(p)
+		if !ok {
+			return nil
+		}
+		pipes = append(pipes, []bson.M{
+			{"$match": bson.M{"lang": p.Lang(), "plural": plural, "key": term}},
+			{"$lookup": bson.M{"from": "weighted_pages", "localField": "pageid", "foreignField": "pageid", "as": "terms"}},
+			{"$unwind": "$terms"},
+			{"$match": bson.M{"terms.plural": bson.M{"$in": plurals}}},
+			{"$group": bson.M{
+				"_id":   bson.M{"plural": "$terms.plural", "key": "$terms.key"},
+				"count": bson.M{"$sum": 1},
+			}},
+		})
+	default:
+		for from := 0; from < len(p.PageIds); from += facetPageIdsBatchSize {
+			to := from + facetPageIdsBatchSize
+			if to > len(p.PageIds) {
+				to = len(p.PageIds)
+			}
+			pipes = append(pipes, []bson.M{
+				{"$match": bson.M{"pageid": bson.M{"$in": p.PageIds[from:to]}, "plural": bson.M{"$in": plurals}}},
+				group,
+			})
+		}
+	}
+
+	byKey := make(map[string]map[string]int)
+
+	for _, pipe := range pipes {
+		var results []struct {
+			ID struct {
+				Plural string
+				Key    string
+			} `bson:"_id"`
+			Count int
+		}
+
+		if err := wp.Pipe(pipe).AllowDiskUse().All(&results); err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+
+		for _, r := range results {
+			if byKey[r.ID.Plural] == nil {
+				byKey[r.ID.Plural] = make(map[string]int)
+			}
+			byKey[r.ID.Plural][r.ID.Key] += r.Count
+		}
+	}
+
+	counts := make(map[string][]FacetValue, len(byKey))
+	for plural, keys := range byKey {
+		for key, count := range keys {
+			counts[plural] = append(counts[plural], FacetValue{Key: key, Count: count})
+		}
+	}
+
+	return counts
+}
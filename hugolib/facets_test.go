@@ -0,0 +1,145 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeFacetsConfig(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	taxonomies := map[string]string{"brand": "brands", "color": "colors"}
+
+	facets, err := decodeFacetsConfig([]map[string]interface{}{
+		{"taxonomy": "colors", "weight": 2, "limit": "5"},
+		{"taxonomy": "brands", "label": "facetBrands", "order": "key", "weight": 1},
+	}, taxonomies)
+	assert.NoError(err)
+	assert.Equal(FacetsConfig{
+		{Taxonomy: "brands", Label: "facetBrands", Order: facetOrderKey, Weight: 1},
+		{Taxonomy: "colors", Label: "colors", Order: facetOrderCount, Limit: 5, Weight: 2},
+	}, facets)
+	assert.Equal([]string{"brands", "colors"}, facets.taxonomies())
+
+	facets, err = decodeFacetsConfig(nil, taxonomies)
+	assert.NoError(err)
+	assert.Len(facets, 0)
+
+	for _, invalid := range []interface{}{
+		"brands",
+		[]map[string]interface{}{{"taxonomy": "sizes"}},
+		[]map[string]interface{}{{"taxonomy": "brands", "order": "random"}},
+		[]map[string]interface{}{{"taxonomy": "brands", "limit": -1}},
+	} {
+		_, err := decodeFacetsConfig(invalid, taxonomies)
+		assert.Error(err, "%v", invalid)
+	}
+}
+
+func TestSortFacetValues(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	values := []FacetValue{{Key: "b", Count: 1}, {Key: "c", Count: 3}, {Key: "a", Count: 1}}
+
+	sortFacetValues(values, facetOrderCount)
+	assert.Equal([]FacetValue{{Key: "c", Count: 3}, {Key: "a", Count: 1}, {Key: "b", Count: 1}}, values)
+
+	sortFacetValues(values, facetOrderKey)
+	assert.Equal([]FacetValue{{Key: "a", Count: 1}, {Key: "b", Count: 1}, {Key: "c", Count: 3}}, values)
+}
+
+// The products of the facet build tests.
+var facetTestContent = []string{
+	"products/p1.md", "---\ntitle: P1\nweight: 1\nbrands: [acme]\ncolors: [red]\n---\n",
+	"products/p2.md", "---\ntitle: P2\nweight: 2\nbrands: [acme]\ncolors: [blue]\n---\n",
+	"products/p3.md", "---\ntitle: P3\nweight: 3\nbrands: [zeta]\ncolors: [red]\n---\n",
+}
+
+const facetTestConfig = `
+baseURL = "http://example.com/"
+
+[taxonomies]
+brand = "brands"
+color = "colors"
+
+[[facets]]
+taxonomy = "brands"
+[[facets]]
+taxonomy = "colors"
+`
+
+func TestFacetsBuild(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", facetTestConfig)
+	b.WithContent(facetTestContent...)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `{{ range .Facets }}{{ .Title }}:{{ range .Values }} {{ .Key }}={{ .Count }} {{ .URL }}{{ if .Active }} active{{ end }}{{ end }}|{{ end }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/index.html",
+		"brands: acme=2 /brands/acme/ zeta=1 /brands/zeta/|",
+		"colors: red=2 /colors/red/ blue=1 /colors/blue/|")
+	b.AssertFileContent("public/brands/acme/index.html",
+		"brands: acme=2 /brands/acme/ active|",
+		"colors: blue=1 /colors/blue/ red=1 /colors/red/|")
+}
+
+func TestFacetsMultilingualBuild(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", `
+baseURL = "http://example.com/"
+defaultContentLanguage = "en"
+
+[languages]
+[languages.en]
+weight = 1
+[languages.fr]
+weight = 2
+
+[taxonomies]
+brand = "brands"
+color = "colors"
+
+[[facets]]
+taxonomy = "brands"
+[[facets]]
+taxonomy = "colors"
+`)
+	b.WithContent(facetTestContent...)
+	b.WithContent("products/p4.fr.md", "---\ntitle: P4\nbrands: [acme]\ncolors: [blue]\n---\n")
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `{{ range .Facets }}{{ .Taxonomy }}:{{ range .Values }} {{ .Key }}={{ .Count }}{{ end }}|{{ end }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	// Each language counts its own pages only.
+	b.AssertFileContent("public/index.html", "brands: acme=2 zeta=1|colors: red=2 blue=1|")
+	b.AssertFileContent("public/fr/index.html", "brands: acme=1|colors: blue=1|")
+	b.AssertFileContent("public/brands/acme/index.html", "brands: acme=2|colors: blue=1 red=1|")
+	b.AssertFileContent("public/fr/brands/acme/index.html", "brands: acme=1|colors: blue=1|")
+}
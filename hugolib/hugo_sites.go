@@ -50,6 +50,15 @@ type HugoSites struct {
 
 	// If enabled, keeps a revision map for all content.
 	gitInfo *gitInfo
+
+	// Serializes builds and updates of pushed records, see Update, which
+	// write to the store and the taxonomies, against the pages rendered on
+	// request, see RenderPath, which read them.
+	updateMu sync.RWMutex
+
+	// Tells the files published by this render from those of the renders
+	// before, see claimOutputPaths.
+	renderID int64
 }
 
 func (h *HugoSites) IsMultihost() bool {
@@ -383,33 +392,6 @@ func (h *HugoSites) renderCrossSitesArtifacts() error {
 		sitemapDefault.Filename, h.toSiteInfos(), s.appendThemeTemplates(smLayouts)...)
 }
 
-//func (h *HugoSites) assignMissingTranslations() error {
-//
-//	This looks heavy, but it should be a small number of nodes by now.
-//allPages := h.findAllPagesByKindNotIn(KindPage)
-//for _, nodeType := range []string{KindHome, KindSection, KindTaxonomy, KindTaxonomyTerm} {
-//	nodes := h.findPagesByKindIn(nodeType, allPages)
-//
-//	Assign translations
-//for _, t1 := range nodes {
-//	for _, t2 := range nodes {
-//		if t1.isNewTranslation(t2) {
-//			t1.translations = append(t1.translations, t2)
-//		}
-//	}
-//}
-//}
-//
-//Now we can sort the translations.
-//for _, p := range allPages {
-//	if len(p.translations) > 0 {
-//		pageBy(languagePageSort).Sort(p.translations)
-//	}
-//}
-//return nil
-//
-//}
-
 // createMissingPages creates home page, taxonomies etc. that isnt't created as an
 // effect of having a content file.
 func (h *HugoSites) createMissingPages() error {
@@ -482,7 +464,20 @@ func (h *HugoSites) createMissingPages() error {
 							}
 
 							if !foundTaxonomyPage {
+								var count int
+								if t := s.Info.Taxonomies[plural][origKey]; t != nil {
+									count = t.Count
+								}
+
+								thinAction := s.thinPages.action(plural, "", count)
+								if thinAction == thinActionSkip {
+									// Nor is it listed, see dropSkippedTerms.
+									delete(s.Info.Taxonomies[plural], origKey)
+									continue
+								}
+
 								n := s.newTaxonomyPage(plural, origKey)
+								n.thinAction = thinAction
 								//s.Pages = append(s.Pages, n)
 								//newPages = append(newPages, n)
 								s.PageStore.AddToAllPages(n)
@@ -492,6 +487,14 @@ func (h *HugoSites) createMissingPages() error {
 						//})
 					}
 				}
+
+				if s.isEnabled(KindTaxonomy) {
+					existing := make(map[string]bool, len(taxonomyPages))
+					for _, p := range taxonomyPages {
+						existing[p.ID] = true
+					}
+					s.PageStore.AddToAllPages(s.assembleFacetCombinations(existing)...)
+				}
 			}
 		}
 	}
@@ -567,23 +570,7 @@ func (h *HugoSites) setupTranslations() {
 	for i := 1; i < len(h.Sites); i++ {
 		h.Sites[i].Data = h.Sites[0].Data
 	}
-	//TODO DAVID make translations work
-	//if len(h.Sites) > 1 {
-	//	allTranslations := pagesToTranslationsMap(allPages)
-	//	assignTranslationsToPages(allTranslations, allPages)
-	//}
-}
-
-func (s *Site) preparePagesForRender(start bool) {
-	s.PageStore.eachPages(func(p *Page) (error) {
-		p.setContentInit(start)
-
-		return nil
-	}, true, false, false, false)
-
-	s.PageStore.eachHeadlessPages(func(p *Page) {
-		p.setContentInit(start)
-	})
+	// The translations are read from the store, see materializeTranslations.
 }
 
 // Pages returns all pages for all sites.
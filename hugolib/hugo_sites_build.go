@@ -16,6 +16,7 @@ package hugolib
 import (
 	"bytes"
 	"fmt"
+	"time"
 
 	"errors"
 
@@ -28,6 +29,11 @@ import (
 // Build builds all sites. If filesystem events are provided,
 // this is considered to be a potential partial rebuild.
 func (h *HugoSites) Build(config BuildCfg, events ...fsnotify.Event) error {
+	// Pushed records are written to the store the builds read from and
+	// write to, so updates wait for the build, see Update.
+	h.updateMu.Lock()
+	defer h.updateMu.Unlock()
+
 	if h.Metrics != nil {
 		h.Metrics.Reset()
 	}
@@ -206,50 +212,36 @@ func (h *HugoSites) assemble(config *BuildCfg) error {
 				return err
 			}
 
+			s.PageStore.setPageIndex(p)
+
 			return nil
 
 		}, true, false, false, false)
-		//s.assembleMenus()
+
+		s.assembleMenus()
 		//s.refreshPageCaches()
 		//s.setupSitePages()
 	}
 
-	//if err := h.assignMissingTranslations(); err != nil {
-	//	return err
-	//}
+	if h.multilingual.enabled() {
+		h.Sites[0].PageStore.materializeTranslations()
+	}
 
 	return nil
 
 }
 
 func (h *HugoSites) render(config *BuildCfg) error {
-	for _, s := range h.Sites {
-		s.initRenderFormats()
-	}
-
-	for _, s := range h.Sites {
-		for i, rf := range s.renderFormats {
-			for _, s2 := range h.Sites {
-				// We render site by site, but since the content is lazily rendered
-				// and a site can "borrow" content from other sites, every site
-				// needs this set.
-				s2.rc = &siteRenderingContext{Format: rf}
-
-				isRenderingSite := s == s2
-
-				s2.preparePagesForRender(isRenderingSite && i == 0)
-
-			}
+	h.renderID = time.Now().UnixNano()
+	h.initRenderFormats()
 
-			if !config.SkipRender {
-				if err := s.render(config, i); err != nil {
-					return err
-				}
+	if !config.SkipRender {
+		for _, s := range h.Sites {
+			if err := s.render(config); err != nil {
+				return err
 			}
 		}
-	}
 
-	if !config.SkipRender {
 		if err := h.renderCrossSitesArtifacts(); err != nil {
 			return err
 		}
@@ -257,3 +249,16 @@ func (h *HugoSites) render(config *BuildCfg) error {
 
 	return nil
 }
+
+func (h *HugoSites) initRenderFormats() {
+	for _, s := range h.Sites {
+		s.initRenderFormats()
+
+		// Pages render all of their output formats in one pass, each with
+		// its own rendering context, see pageRenderer. The site context is
+		// what pages borrowed by other sites fall back to.
+		if len(s.renderFormats) > 0 {
+			s.rc = &siteRenderingContext{Format: s.renderFormats[0]}
+		}
+	}
+}
@@ -0,0 +1,586 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/gohugoio/hugo/parser"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+
+	// Use this until errgroup gets ported to context
+	// See https://github.com/golang/go/issues/19781
+	"golang.org/x/net/context"
+)
+
+const (
+	ingestFormatJSONL = "jsonl"
+	ingestFormatCSV   = "csv"
+	ingestFormatMongo = "mongo"
+)
+
+// IngestSourceConfig configures a feed of records that are turned into
+// pages as if they were content files, e.g.
+//
+//	[[ingest]]
+//	name = "products"
+//	source = "feeds/products.jsonl"
+//	section = "products/{{ .category | urlize }}"
+//	slug = "{{ .sku }}"
+//	id = "sku"
+//	body = "description"
+//	[ingest.frontMatter]
+//	title = "name"
+//
+// or, for a Mongo collection on the page store server,
+//
+//	[[ingest]]
+//	format = "mongo"
+//	database = "catalog"
+//	collection = "products"
+//	query = { active = true }
+//	...
+type IngestSourceConfig struct {
+	// Used to ingest one source with hugo ingest. Defaults to the source.
+	Name string
+
+	// The JSONL or CSV file, relative to the working dir.
+	Source string
+
+	// "jsonl", "csv" or "mongo". Defaults to the extension of the source.
+	Format string
+
+	// The field separator of a CSV source. Defaults to ",".
+	Delimiter string
+
+	// The Mongo database, collection and filter of a mongo source.
+	Database   string
+	Collection string
+	Query      map[string]interface{}
+
+	// Templates over the record giving the section path and the file name
+	// of the page, without extension.
+	Section string
+	Slug    string
+
+	// The record field that identifies a record across updates, e.g. "sku".
+	// When set, an update of a record whose section or slug changed
+	// replaces the page the record was ingested as, instead of adding one.
+	ID string
+
+	// The field holding the content of the page.
+	Body string
+
+	// Front matter keys by record field. Without any, all fields but the
+	// body become front matter as they are.
+	FrontMatter map[string]string
+
+	// Fields parsed as numbers, e.g. prices in a CSV source.
+	Numbers []string
+
+	// The language of the pages. Defaults to the default content language.
+	Lang string
+
+	sectionTemplate *template.Template
+	slugTemplate    *template.Template
+}
+
+// decodeIngestConfig reads the ingest sources of a site.
+func decodeIngestConfig(in interface{}) ([]IngestSourceConfig, error) {
+	if in == nil {
+		return nil, nil
+	}
+
+	items, err := cast.ToSliceE(in)
+	if err != nil {
+		return nil, fmt.Errorf("ingest must be a list: %s", err)
+	}
+
+	sources := make([]IngestSourceConfig, 0, len(items))
+	names := make(map[string]bool)
+
+	for _, item := range items {
+		var c IngestSourceConfig
+		if err := mapstructure.WeakDecode(cast.ToStringMap(item), &c); err != nil {
+			return nil, fmt.Errorf("failed to decode ingest source: %s", err)
+		}
+
+		if c.Format == "" {
+			c.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(c.Source)), ".")
+		}
+
+		switch c.Format {
+		case ingestFormatJSONL, ingestFormatCSV:
+			if c.Source == "" {
+				return nil, fmt.Errorf("ingest source of format %q needs a source file", c.Format)
+			}
+		case ingestFormatMongo:
+			if c.Database == "" || c.Collection == "" {
+				return nil, fmt.Errorf("ingest source of format %q needs a database and a collection", c.Format)
+			}
+			if c.Source == "" {
+				c.Source = c.Database + "." + c.Collection
+			}
+		default:
+			return nil, fmt.Errorf("ingest format must be %q, %q or %q, got %q", ingestFormatJSONL, ingestFormatCSV, ingestFormatMongo, c.Format)
+		}
+
+		if c.Name == "" {
+			c.Name = c.Source
+		}
+
+		if names[c.Name] {
+			return nil, fmt.Errorf("more than one ingest source named %q", c.Name)
+		}
+		names[c.Name] = true
+
+		if c.Slug == "" {
+			return nil, fmt.Errorf("ingest source %q needs a slug", c.Name)
+		}
+
+		if c.sectionTemplate, err = newIngestTemplate(c.Name+" section", c.Section); err != nil {
+			return nil, err
+		}
+
+		if c.slugTemplate, err = newIngestTemplate(c.Name+" slug", c.Slug); err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, c)
+	}
+
+	return sources, nil
+}
+
+var ingestTemplateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"urlize": func(s interface{}) string {
+		return strings.Trim(ingestURLizeReplacer.Replace(strings.ToLower(cast.ToString(s))), "-")
+	},
+}
+
+var ingestURLizeReplacer = strings.NewReplacer(" ", "-", "/", "-", "\\", "-", "?", "", "#", "", "%", "", "&", "and")
+
+func newIngestTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(ingestTemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ingest template %q: %s", name, err)
+	}
+	return t, nil
+}
+
+func executeIngestTemplate(t *template.Template, record map[string]interface{}) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, record); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.Replace(b.String(), "<no value>", "", -1)), nil
+}
+
+// filename returns the content file the record is ingested as, relative to
+// the content dir.
+func (c IngestSourceConfig) filename(record map[string]interface{}, multilingual bool) (string, error) {
+	section, err := executeIngestTemplate(c.sectionTemplate, record)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute the section template of ingest source %q: %s", c.Name, err)
+	}
+
+	slug, err := executeIngestTemplate(c.slugTemplate, record)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute the slug template of ingest source %q: %s", c.Name, err)
+	}
+
+	if slug == "" {
+		return "", fmt.Errorf("record of ingest source %q has an empty slug", c.Name)
+	}
+
+	for _, part := range strings.Split(section+"/"+slug, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("record of ingest source %q is outside the content dir: %q", c.Name, path.Join(section, slug))
+		}
+	}
+
+	ext := ".md"
+	if multilingual && c.Lang != "" {
+		ext = "." + c.Lang + ext
+	}
+
+	return path.Join(section, slug+ext), nil
+}
+
+// recordKey returns what identifies the page of a record across updates,
+// see ID, or "" if the source has no ID.
+func (c IngestSourceConfig) recordKey(record map[string]interface{}) (string, error) {
+	if c.ID == "" {
+		return "", nil
+	}
+
+	id := cast.ToString(record[c.ID])
+	if id == "" {
+		return "", fmt.Errorf("record of ingest source %q has no %s", c.Name, c.ID)
+	}
+
+	return c.Name + ":" + id, nil
+}
+
+// frontMatterAndBody splits a record into the front matter and the content
+// of its page.
+func (c IngestSourceConfig) frontMatterAndBody(record map[string]interface{}) (map[string]interface{}, string) {
+	body := ""
+	if c.Body != "" {
+		body = cast.ToString(record[c.Body])
+	}
+
+	frontMatter := make(map[string]interface{})
+
+	if len(c.FrontMatter) == 0 {
+		for k, v := range record {
+			if k != c.Body && k != "_id" {
+				frontMatter[k] = v
+			}
+		}
+	} else {
+		for key, field := range c.FrontMatter {
+			if v, found := record[field]; found {
+				frontMatter[key] = v
+			}
+		}
+	}
+
+	for _, field := range c.Numbers {
+		for key, v := range frontMatter {
+			if key == field || c.FrontMatter[key] == field {
+				if s, ok := v.(string); ok {
+					frontMatter[key] = parseIngestNumber(s)
+				}
+			}
+		}
+	}
+
+	return frontMatter, body
+}
+
+func parseIngestNumber(s string) interface{} {
+	s = strings.TrimSpace(s)
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// normalizeIngestValue turns values as decoded from JSON or BSON into ones
+// that encode as front matter.
+func normalizeIngestValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case json.Number:
+		if i, err := vv.Int64(); err == nil {
+			return i
+		}
+		f, _ := vv.Float64()
+		return f
+	case bson.ObjectId:
+		return vv.Hex()
+	case map[string]interface{}:
+		for k, e := range vv {
+			vv[k] = normalizeIngestValue(e)
+		}
+		return vv
+	case bson.M:
+		return normalizeIngestValue(map[string]interface{}(vv))
+	case []interface{}:
+		for i, e := range vv {
+			vv[i] = normalizeIngestValue(e)
+		}
+		return vv
+	}
+	return v
+}
+
+// newPageFromRecord creates the page of a record the way a content file
+// with its front matter and body would be read.
+func (s *Site) newPageFromRecord(c IngestSourceConfig, record map[string]interface{}) (*Page, error) {
+	for k, v := range record {
+		record[k] = normalizeIngestValue(v)
+	}
+
+	filename, err := c.filename(record, s.multilingualEnabled())
+	if err != nil {
+		return nil, err
+	}
+
+	recordKey, err := c.recordKey(record)
+	if err != nil {
+		return nil, err
+	}
+
+	frontMatter, body := c.frontMatterAndBody(record)
+
+	var content bytes.Buffer
+	if err := parser.InterfaceToFrontMatter(frontMatter, rune(parser.YAMLLead[0]), &content); err != nil {
+		return nil, fmt.Errorf("failed to write the front matter of %q: %s", filename, err)
+	}
+	content.WriteString(body)
+
+	p := s.newPage(filepath.Join(s.absContentDir(), filepath.FromSlash(filename)))
+
+	if _, err := p.ReadFrom(&content); err != nil {
+		return nil, err
+	}
+
+	p.recordKey = recordKey
+
+	return p, nil
+}
+
+// ingestRecords reads the records of an ingest source one at a time.
+type ingestRecords interface {
+	// Next returns io.EOF after the last record.
+	Next() (map[string]interface{}, error)
+	Close() error
+}
+
+func (ps *PageStore) openIngestSource(c IngestSourceConfig) (ingestRecords, error) {
+	switch c.Format {
+	case ingestFormatMongo:
+		query := ps.MongoSession.DB(c.Database).C(c.Collection).Find(c.Query).Batch(1000)
+		return &mongoIngestRecords{iter: query.Iter()}, nil
+	}
+
+	f, err := os.Open(ps.Site.PathSpec.AbsPathify(c.Source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ingest source %q: %s", c.Name, err)
+	}
+
+	if c.Format == ingestFormatCSV {
+		r := csv.NewReader(f)
+		if c.Delimiter != "" {
+			r.Comma = []rune(c.Delimiter)[0]
+		}
+		r.ReuseRecord = true
+
+		header, err := r.Read()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read the header of ingest source %q: %s", c.Name, err)
+		}
+
+		return &csvIngestRecords{f: f, r: r, header: append([]string(nil), header...)}, nil
+	}
+
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+
+	return &jsonlIngestRecords{f: f, dec: dec}, nil
+}
+
+type jsonlIngestRecords struct {
+	f   *os.File
+	dec *json.Decoder
+}
+
+func (r *jsonlIngestRecords) Next() (map[string]interface{}, error) {
+	var record map[string]interface{}
+	if err := r.dec.Decode(&record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (r *jsonlIngestRecords) Close() error {
+	return r.f.Close()
+}
+
+type csvIngestRecords struct {
+	f      *os.File
+	r      *csv.Reader
+	header []string
+}
+
+func (r *csvIngestRecords) Next() (map[string]interface{}, error) {
+	row, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	record := make(map[string]interface{}, len(r.header))
+	for i, field := range r.header {
+		if i < len(row) {
+			record[field] = row[i]
+		}
+	}
+
+	return record, nil
+}
+
+func (r *csvIngestRecords) Close() error {
+	return r.f.Close()
+}
+
+type mongoIngestRecords struct {
+	iter *mgo.Iter
+}
+
+func (r *mongoIngestRecords) Next() (map[string]interface{}, error) {
+	var record bson.M
+	if !r.iter.Next(&record) {
+		if err := r.iter.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return map[string]interface{}(record), nil
+}
+
+func (r *mongoIngestRecords) Close() error {
+	return r.iter.Close()
+}
+
+// ingestSource streams the pages of the records of an ingest source to add.
+// It returns the number of pages.
+func (s *Site) ingestSource(c IngestSourceConfig, add func(p *Page) error) (int, error) {
+	records, err := s.PageStore.openIngestSource(c)
+	if err != nil {
+		return 0, err
+	}
+	defer records.Close()
+
+	n := 0
+
+	for {
+		record, err := records.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("failed to read record %d of ingest source %q: %s", n+1, c.Name, err)
+		}
+
+		p, err := s.newPageFromRecord(c, record)
+		if err != nil {
+			return n, fmt.Errorf("record %d of ingest source %q: %s", n+1, c.Name, err)
+		}
+
+		if !p.shouldBuild() || !p.checkCollectionQuery() {
+			continue
+		}
+
+		if err := add(p); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+}
+
+// languageIngestSources returns the ingest sources of the language of s.
+func (s *Site) languageIngestSources() []IngestSourceConfig {
+	lang := s.Language.Lang
+
+	var sources []IngestSourceConfig
+	for _, c := range s.ingestSources {
+		if c.Lang == lang || (c.Lang == "" && lang == s.Cfg.GetString("defaultContentLanguage")) {
+			sources = append(sources, c)
+		}
+	}
+
+	return sources
+}
+
+// ingestContent adds the pages of the ingest sources of the site to a full
+// build, next to the pages of the content files. It runs in the group that
+// sends to pagesChan, which is only closed once the group is done.
+func (s *siteContentProcessor) ingestContent(ctx context.Context) error {
+	for _, c := range s.site.languageIngestSources() {
+		n, err := s.site.ingestSource(c, func(p *Page) error {
+			select {
+			case s.pagesChan <- p:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			return err
+		}
+		s.site.Log.INFO.Printf("Ingested %d pages from %q", n, c.Name)
+	}
+	return nil
+}
+
+// Ingest upserts the pages of the ingest sources, or of the named ones,
+// into the page store without building. Pages ingested before from the
+// same records are replaced. It returns the number of pages per source.
+func (h *HugoSites) Ingest(names ...string) (map[string]int, error) {
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	counts := make(map[string]int)
+
+	for _, s := range h.Sites {
+		enricher, err := s.newPageEnricher()
+		if err != nil {
+			return counts, err
+		}
+
+		for _, c := range s.languageIngestSources() {
+			if len(wanted) > 0 && !wanted[c.Name] {
+				continue
+			}
+
+			upserter := s.PageStore.newPageUpserter(context.Background(), "pages")
+
+			n, err := s.ingestSource(c, func(p *Page) error {
+				enricher.enrich(p)
+				return upserter.Add(p)
+			})
+
+			if cerr := upserter.Close(); err == nil {
+				err = cerr
+			}
+
+			counts[c.Name] += n
+
+			if err != nil {
+				return counts, err
+			}
+		}
+	}
+
+	for _, name := range names {
+		if _, found := counts[name]; !found {
+			return counts, fmt.Errorf("no ingest source named %q", name)
+		}
+	}
+
+	return counts, nil
+}
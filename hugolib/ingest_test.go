@@ -0,0 +1,252 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeIngestConfig(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	sources, err := decodeIngestConfig(nil)
+	assert.NoError(err)
+	assert.Len(sources, 0)
+
+	sources, err = decodeIngestConfig([]interface{}{
+		map[string]interface{}{"source": "feeds/products.jsonl", "slug": "{{ .sku }}"},
+		map[string]interface{}{"name": "stock", "format": "mongo", "database": "catalog", "collection": "stock", "slug": "{{ .sku }}"},
+	})
+	assert.NoError(err)
+	assert.Len(sources, 2)
+	assert.Equal("jsonl", sources[0].Format)
+	assert.Equal("feeds/products.jsonl", sources[0].Name)
+	assert.Equal("catalog.stock", sources[1].Source)
+	assert.Equal("stock", sources[1].Name)
+
+	for _, in := range []interface{}{
+		"products",
+		[]interface{}{map[string]interface{}{"source": "feeds/products.xml", "slug": "{{ .sku }}"}},
+		[]interface{}{map[string]interface{}{"format": "csv", "slug": "{{ .sku }}"}},
+		[]interface{}{map[string]interface{}{"format": "mongo", "database": "catalog", "slug": "{{ .sku }}"}},
+		[]interface{}{map[string]interface{}{"source": "feeds/products.csv"}},
+		[]interface{}{map[string]interface{}{"source": "feeds/products.csv", "slug": "{{ .sku"}},
+		[]interface{}{
+			map[string]interface{}{"name": "products", "source": "a.csv", "slug": "{{ .sku }}"},
+			map[string]interface{}{"name": "products", "source": "b.csv", "slug": "{{ .sku }}"},
+		},
+	} {
+		_, err := decodeIngestConfig(in)
+		assert.Error(err, "%v", in)
+	}
+}
+
+func TestIngestFilename(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	sources, err := decodeIngestConfig([]interface{}{
+		map[string]interface{}{
+			"source":  "feeds/products.csv",
+			"section": "products/{{ .category | urlize }}",
+			"slug":    "{{ .sku }}",
+			"lang":    "de",
+		},
+	})
+	assert.NoError(err)
+	c := sources[0]
+
+	record := map[string]interface{}{"category": "Home & Garden", "sku": "A-1"}
+
+	filename, err := c.filename(record, false)
+	assert.NoError(err)
+	assert.Equal("products/home-and-garden/A-1.md", filename)
+
+	filename, err = c.filename(record, true)
+	assert.NoError(err)
+	assert.Equal("products/home-and-garden/A-1.de.md", filename)
+
+	filename, err = c.filename(map[string]interface{}{"sku": "A-2"}, false)
+	assert.NoError(err)
+	assert.Equal("products/A-2.md", filename)
+
+	_, err = c.filename(map[string]interface{}{"category": "Garden"}, false)
+	assert.Error(err)
+
+	_, err = c.filename(map[string]interface{}{"sku": "../../etc/passwd"}, false)
+	assert.Error(err)
+}
+
+func TestIngestRecordKey(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	c := IngestSourceConfig{Name: "products"}
+
+	key, err := c.recordKey(map[string]interface{}{"sku": "A-1"})
+	assert.NoError(err)
+	assert.Equal("", key)
+
+	c.ID = "sku"
+
+	key, err = c.recordKey(map[string]interface{}{"sku": "A-1"})
+	assert.NoError(err)
+	assert.Equal("products:A-1", key)
+
+	_, err = c.recordKey(map[string]interface{}{"name": "Lamp"})
+	assert.Error(err)
+}
+
+func TestIngestFrontMatterAndBody(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	record := map[string]interface{}{"_id": "x", "name": "Lamp", "price": "12.50", "stock": "3", "description": "A lamp."}
+
+	c := IngestSourceConfig{Body: "description", Numbers: []string{"price", "stock"}}
+
+	frontMatter, body := c.frontMatterAndBody(record)
+	assert.Equal("A lamp.", body)
+	assert.Equal(map[string]interface{}{"name": "Lamp", "price": 12.5, "stock": int64(3)}, frontMatter)
+
+	c = IngestSourceConfig{FrontMatter: map[string]string{"title": "name", "cost": "price"}, Numbers: []string{"price"}}
+
+	frontMatter, body = c.frontMatterAndBody(record)
+	assert.Equal("", body)
+	assert.Equal(map[string]interface{}{"title": "Lamp", "cost": 12.5}, frontMatter)
+}
+
+func TestParseIngestNumber(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	assert.Equal(int64(42), parseIngestNumber(" 42 "))
+	assert.Equal(4.2, parseIngestNumber("4.2"))
+	assert.Equal("n/a", parseIngestNumber("n/a"))
+}
+
+func TestNormalizeIngestValue(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	v := normalizeIngestValue(map[string]interface{}{
+		"count": json.Number("3"),
+		"sizes": []interface{}{json.Number("1.5"), "XL"},
+	})
+
+	assert.Equal(map[string]interface{}{
+		"count": int64(3),
+		"sizes": []interface{}{1.5, "XL"},
+	}, v)
+}
+
+func TestIngestSourceBuild(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	// More records than pagesChan holds, so that ingesting has to wait for
+	// the pages to be written.
+	const numRecords = 50
+
+	dir, err := ioutil.TempDir("", "hugo-ingest")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	var records bytes.Buffer
+	for i := 1; i <= numRecords; i++ {
+		fmt.Fprintf(&records, `{"sku": "p%d", "name": "Product %d"}`+"\n", i, i)
+	}
+	source := filepath.Join(dir, "products.jsonl")
+	assert.NoError(ioutil.WriteFile(source, records.Bytes(), 0644))
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", fmt.Sprintf(`
+baseURL = "http://example.com/"
+
+[[ingest]]
+name = "products"
+source = %q
+section = "products"
+slug = "{{ .sku }}"
+[ingest.frontMatter]
+title = "name"
+`, source))
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `{{ .Title }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	count, err := b.H.Sites[0].PageStore.MongoSession.DB("hugo").C("pages").Find(bson.M{"kind": KindPage}).Count()
+	assert.NoError(err)
+	assert.Equal(numRecords, count)
+
+	b.AssertFileContent("public/products/p1/index.html", "Product 1")
+	b.AssertFileContent(fmt.Sprintf("public/products/p%d/index.html", numRecords), fmt.Sprintf("Product %d", numRecords))
+}
+
+func TestIngestCSVLitePagesBuild(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "hugo-ingest")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// Every value of a CSV source is a string.
+	source := filepath.Join(dir, "products.csv")
+	assert.NoError(ioutil.WriteFile(source, []byte(`page_human_id,name,image,stars_class,master_variation,tags,price
+shirt,Shirt,/img/shirt.png,stars-4,true,cotton summer,19
+hat,Hat,,,false,,
+`), 0644))
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", fmt.Sprintf(`
+baseURL = "http://example.com/"
+
+[[ingest]]
+name = "products"
+source = %q
+section = "products"
+slug = "{{ .page_human_id }}"
+[ingest.frontMatter]
+title = "name"
+page_human_id = "page_human_id"
+image = "image"
+stars_class = "stars_class"
+master_variation = "master_variation"
+tags = "tags"
+price = "price"
+`, source))
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `{{ .Title }}`,
+		"index.html", `{{ range slice "shirt" "hat" }}{{ with $.Site.GetLitePageBy "page_human_id" . }}{{ .Title }}:{{ .Image }}:{{ .StarsClass }}:{{ .MasterVariation }}:{{ .Tags }}:{{ .Price }}|{{ end }}{{ end }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/index.html", "Shirt:/img/shirt.png:stars-4:true:[cotton summer]:19|", "Hat::::false:[]:0|")
+}
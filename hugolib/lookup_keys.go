@@ -0,0 +1,265 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/spf13/cast"
+)
+
+// defaultLookupKey is the param pages were looked up by before lookup keys
+// could be configured.
+const defaultLookupKey = "page_human_id"
+
+// How many duplicate values of a lookup key are reported per build.
+const maxReportedLookupDuplicates = 20
+
+// decodeLookupKeys reads the lookupKeys config, the params that identify a
+// page, e.g.
+//
+//	lookupKeys = ["page_human_id", "sku", "ean", "supplier_id"]
+func decodeLookupKeys(in interface{}) ([]string, error) {
+	if in == nil {
+		return []string{defaultLookupKey}, nil
+	}
+
+	keys, err := cast.ToStringSliceE(in)
+	if err != nil {
+		return nil, fmt.Errorf("lookupKeys must be a list of params: %s", err)
+	}
+
+	seen := make(map[string]bool)
+
+	for i, key := range keys {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			return nil, fmt.Errorf("lookupKeys must not contain an empty param")
+		}
+		if strings.Contains(key, ":") {
+			return nil, fmt.Errorf("lookupKeys param %q must not contain a colon", key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("lookupKeys contains %q more than once", key)
+		}
+		seen[key] = true
+		keys[i] = key
+	}
+
+	return keys, nil
+}
+
+// litePageKey returns the RocksDB key of the lite page of a language with
+// the given value of the lookup key. Lookup keys have no colon, so neither
+// the key nor the value can be mistaken for part of the other.
+func litePageKey(lang, key, value string) string {
+	return "lite:" + lang + ":" + key + ":" + value
+}
+
+// lookupValues returns the values a lookup value matches in the store. Ids
+// given as strings in templates also match ids stored as numbers.
+func lookupValues(value interface{}) []interface{} {
+	values := []interface{}{value}
+
+	if s, ok := value.(string); ok {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			values = append(values, i)
+		}
+	} else {
+		values = append(values, cast.ToString(value))
+	}
+
+	return values
+}
+
+// lookupParam returns the value of the lookup key of p as used in RocksDB
+// keys, and false if p has none.
+func (p *Page) lookupParam(key string) (string, bool) {
+	v := p.params[key]
+	if v == nil {
+		return "", false
+	}
+
+	s := cast.ToString(v)
+
+	return s, s != ""
+}
+
+func (s *Site) isLookupKey(key string) bool {
+	for _, k := range s.lookupKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// setLitePages writes the lite page of p by its id, and by each of the
+// lookup keys p has.
+func (ps *PageStore) setLitePages(p *Page, keys []string) {
+	ps.setLitePage("id_"+p.ID, p)
+
+	for _, key := range keys {
+		if value, ok := p.lookupParam(key); ok {
+			ps.setLitePage(litePageKey(p.Lang(), key, value), p)
+		}
+	}
+}
+
+// removeLitePages removes the lite pages written by setLitePages for p,
+// leaving those of its lookup values that resolve to another page.
+func (ps *PageStore) removeLitePages(p *Page, keys []string) {
+	ps.RDBDelete("id_" + p.ID)
+
+	for _, key := range keys {
+		value, ok := p.lookupParam(key)
+		if !ok {
+			continue
+		}
+
+		litePageKey := litePageKey(p.Lang(), key, value)
+
+		var litePage LitePage
+		if err := json.Unmarshal([]byte(ps.RDBGet(litePageKey)), &litePage); err == nil && litePage.Permalink == p.Permalink() {
+			ps.RDBDelete(litePageKey)
+		}
+	}
+}
+
+func (ps *PageStore) getPageBy(key string, value interface{}) *Page {
+	pageModel := PageModel{}
+	err := ps.MongoSession.DB("hugo").C("pages").Find(bson.M{"lang": ps.Site.Language.Lang, "params." + key: bson.M{"$in": lookupValues(value)}}).One(&pageModel)
+
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	page := ps.pageModelToPage(&pageModel)
+	ps.loadPageIds(&page)
+
+	return &page
+}
+
+func (ps *PageStore) getPagesBy(key string, values []interface{}) Pages {
+	var in []interface{}
+	for _, v := range values {
+		in = append(in, lookupValues(v)...)
+	}
+
+	var results []PageModel
+	err := ps.MongoSession.DB("hugo").C("pages").Find(bson.M{"lang": ps.Site.Language.Lang, "params." + key: bson.M{"$in": in}}).All(&results)
+
+	if err != nil && err != mgo.ErrNotFound {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	pages := make(Pages, 0, len(results))
+
+	for i := range results {
+		page := ps.pageModelToPage(&results[i])
+		ps.loadPageIds(&page)
+		pages = append(pages, &page)
+	}
+
+	return pages
+}
+
+func (ps *PageStore) getLitePageBy(key string, value interface{}) *LitePage {
+	litePageBytes := ps.RDBGet(litePageKey(ps.Site.Language.Lang, key, cast.ToString(value)))
+
+	if len(litePageBytes) == 0 {
+		return nil
+	}
+
+	var litePage LitePage
+	json.Unmarshal([]byte(litePageBytes), &litePage)
+
+	return &litePage
+}
+
+// lookupDuplicate is a value of a lookup key shared by more than one page.
+type lookupDuplicate struct {
+	Value   interface{} `bson:"_id"`
+	PageIds []string    `bson:"pageids"`
+}
+
+func (ps *PageStore) duplicateLookupValues(lang, key string, limit int) []lookupDuplicate {
+	pipe := []bson.M{
+		{"$match": bson.M{"lang": lang, "params." + key: bson.M{"$exists": true, "$nin": []interface{}{nil, ""}}}},
+		{"$group": bson.M{"_id": "$params." + key, "pageids": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+		{"$sort": bson.M{"_id": 1}},
+		{"$limit": limit},
+	}
+
+	var duplicates []lookupDuplicate
+	if err := ps.MongoSession.DB("hugo").C("pages").Pipe(pipe).AllowDiskUse().All(&duplicates); err != nil && err != mgo.ErrNotFound {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return duplicates
+}
+
+// reportDuplicateLookupValues warns about the values of the lookup keys that more
+// than one page of the language has. Only one of them can be looked up.
+func (s *Site) reportDuplicateLookupValues() {
+	for _, key := range s.lookupKeys {
+		for _, d := range s.PageStore.duplicateLookupValues(s.Language.Lang, key, maxReportedLookupDuplicates) {
+			s.Log.WARN.Printf("%d pages have %s %v: %s", len(d.PageIds), key, d.Value, strings.Join(d.PageIds, ", "))
+		}
+	}
+}
+
+// GetPageBy returns the page with the given value of a lookup key, nil if
+// there is none.
+//
+//	{{ with .Site.GetPageBy "sku" "123" }}{{ .Title }}{{ end }}
+func (siteInfo *SiteInfo) GetPageBy(key string, value interface{}) (*Page, error) {
+	key = strings.ToLower(key)
+	if !siteInfo.s.isLookupKey(key) {
+		return nil, fmt.Errorf("%q is not one of the lookupKeys", key)
+	}
+	return siteInfo.s.PageStore.getPageBy(key, value), nil
+}
+
+// GetPagesBy returns the pages with any of the given values of a lookup key.
+func (siteInfo *SiteInfo) GetPagesBy(key string, values []interface{}) (Pages, error) {
+	key = strings.ToLower(key)
+	if !siteInfo.s.isLookupKey(key) {
+		return nil, fmt.Errorf("%q is not one of the lookupKeys", key)
+	}
+	return siteInfo.s.PageStore.getPagesBy(key, values), nil
+}
+
+// GetLitePageBy returns the lite page with the given value of a lookup
+// key, nil if there is none.
+func (siteInfo *SiteInfo) GetLitePageBy(key string, value interface{}) (*LitePage, error) {
+	key = strings.ToLower(key)
+	if !siteInfo.s.isLookupKey(key) {
+		return nil, fmt.Errorf("%q is not one of the lookupKeys", key)
+	}
+	return siteInfo.s.PageStore.getLitePageBy(key, value), nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeLookupKeys(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	keys, err := decodeLookupKeys(nil)
+	assert.NoError(err)
+	assert.Equal([]string{defaultLookupKey}, keys)
+
+	keys, err = decodeLookupKeys([]interface{}{"page_human_id", "SKU", " ean "})
+	assert.NoError(err)
+	assert.Equal([]string{"page_human_id", "sku", "ean"}, keys)
+
+	_, err = decodeLookupKeys([]interface{}{"sku", "Sku"})
+	assert.Error(err)
+	_, err = decodeLookupKeys([]interface{}{""})
+	assert.Error(err)
+	_, err = decodeLookupKeys([]interface{}{"sku:ean"})
+	assert.Error(err)
+}
+
+func TestLookupKeySpacesAndValues(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	assert.Equal("lite:en:page_human_id:shirt", litePageKey("en", defaultLookupKey, "shirt"))
+	assert.Equal("lite:fr:sku:1", litePageKey("fr", "sku", "1"))
+	assert.NotEqual(litePageKey("en", "supplier", "id_5"), litePageKey("en", "supplier_id", "5"))
+
+	assert.Equal([]interface{}{"123", int64(123)}, lookupValues("123"))
+	assert.Equal([]interface{}{"abc"}, lookupValues("abc"))
+	assert.Equal([]interface{}{123, "123"}, lookupValues(123))
+}
+
+func TestGetPageByLookupKey(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", `
+baseURL = "http://example.com/"
+defaultContentLanguage = "en"
+lookupKeys = ["page_human_id", "sku", "supplier", "supplier_id"]
+
+[languages]
+[languages.en]
+weight = 1
+[languages.fr]
+weight = 2
+`)
+	// The page_human_id of the shirt looks like the sku key space of the hat,
+	// and the supplier of the belt like the supplier_id of the scarf.
+	b.WithContent(
+		"products/shirt.md", "---\ntitle: Shirt\npage_human_id: sku_1\n---\n",
+		"products/shirt.fr.md", "---\ntitle: Chemise\npage_human_id: sku_1\n---\n",
+		"products/hat.md", "---\ntitle: Hat\nsku: 1\n---\n",
+		"products/belt.md", "---\ntitle: Belt\nsupplier: id_5\n---\n",
+		"products/scarf.md", "---\ntitle: Scarf\nsupplier_id: 5\n---\n",
+	)
+	b.WithTemplates(
+		"index.html", `Lite: {{ with .Site.GetLitePageBy "page_human_id" "sku_1" }}{{ .Title }}{{ end }}|`+
+			`{{ with .Site.GetLitePageBy "sku" "1" }}{{ .Title }}{{ end }}|`+
+			`Page: {{ with .Site.GetPageBy "page_human_id" "sku_1" }}{{ .Title }}{{ end }}|`+
+			`Supplier: {{ with .Site.GetLitePageBy "supplier" "id_5" }}{{ .Title }}{{ end }}|`+
+			`{{ with .Site.GetLitePageBy "supplier_id" "5" }}{{ .Title }}{{ end }}|`,
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `{{ .Title }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/index.html", "Lite: Shirt|Hat|", "Page: Shirt|", "Supplier: Belt|Scarf|")
+	b.AssertFileContent("public/fr/index.html", "Lite: Chemise||", "Page: Chemise|")
+}
@@ -0,0 +1,198 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// The RocksDB key space of the menu entries of the languages, see
+// storeMenuEntries.
+const menusKeySpace = "menus"
+
+// storedMenuEntry is a menu entry of the config or of a page, as stored.
+// The menu trees are built from them when the menus are loaded.
+type storedMenuEntry struct {
+	Menu       string        `json:"menu"`
+	Identifier string        `json:"identifier,omitempty"`
+	Name       string        `json:"name,omitempty"`
+	Title      string        `json:"title,omitempty"`
+	URL        string        `json:"url,omitempty"`
+	Pre        template.HTML `json:"pre,omitempty"`
+	Post       template.HTML `json:"post,omitempty"`
+	Weight     int           `json:"weight,omitempty"`
+	Parent     string        `json:"parent,omitempty"`
+	PageId     PageId        `json:"page,omitempty"`
+}
+
+func newStoredMenuEntry(menu string, me *MenuEntry) storedMenuEntry {
+	e := storedMenuEntry{
+		Menu:       menu,
+		Identifier: me.Identifier,
+		Name:       me.Name,
+		Title:      me.title,
+		URL:        me.URL,
+		Pre:        me.Pre,
+		Post:       me.Post,
+		Weight:     me.Weight,
+		Parent:     me.Parent,
+	}
+
+	if me.Page != nil {
+		e.PageId = PageId(me.Page.ID)
+	}
+
+	return e
+}
+
+func (e storedMenuEntry) keyName() string {
+	if e.Identifier != "" {
+		return e.Identifier
+	}
+	return e.Name
+}
+
+// buildMenus builds the menu trees of the given entries, attaching the
+// given pages. Parents no entry is given for are added without a URL.
+func buildMenus(entries []storedMenuEntry, pages map[PageId]*Page) Menus {
+	type twoD struct {
+		MenuName, EntryName string
+	}
+
+	var (
+		menus    = Menus{}
+		flat     = make(map[twoD]*MenuEntry)
+		children = make(map[twoD]Menu)
+		order    []twoD
+	)
+
+	for _, e := range entries {
+		key := twoD{e.Menu, e.keyName()}
+		flat[key] = &MenuEntry{
+			Menu:       e.Menu,
+			Identifier: e.Identifier,
+			Name:       e.Name,
+			title:      e.Title,
+			URL:        e.URL,
+			Pre:        e.Pre,
+			Post:       e.Post,
+			Weight:     e.Weight,
+			Parent:     e.Parent,
+			Page:       pages[e.PageId],
+		}
+		order = append(order, key)
+	}
+
+	// Create Children Menus First
+	for _, key := range order {
+		if e := flat[key]; e.Parent != "" {
+			parent := twoD{e.Menu, e.Parent}
+			children[parent] = children[parent].add(e)
+		}
+	}
+
+	// Placing Children in Parents (in flat)
+	for parent, childmenu := range children {
+		if _, ok := flat[parent]; !ok {
+			flat[parent] = &MenuEntry{Name: parent.EntryName, URL: ""}
+			order = append(order, parent)
+		}
+		flat[parent].Children = childmenu
+	}
+
+	// Assembling Top Level of Tree
+	for _, key := range order {
+		if e := flat[key]; e.Parent == "" {
+			if _, ok := menus[key.MenuName]; !ok {
+				menus[key.MenuName] = &Menu{}
+			}
+			*menus[key.MenuName] = menus[key.MenuName].add(e)
+		}
+	}
+
+	return menus
+}
+
+// eachMenuPage calls f for the pages of the language with menu entries in
+// their front matter and, if withSections is set, for its top level
+// sections, without going through all the pages.
+func (ps *PageStore) eachMenuPage(lang string, withSections bool, f func(*Page)) {
+	matches := []bson.M{{"params.menu": bson.M{"$exists": true}}}
+	if withSections {
+		matches = append(matches, bson.M{"kind": KindSection, "sections": bson.M{"$size": 1}})
+	}
+
+	item := PageModel{}
+	items := ps.MongoSession.DB("hugo").C("pages").Find(bson.M{"lang": lang, "$or": matches}).Sort("_id").Batch(500).Iter()
+
+	for items.Next(&item) {
+		page := ps.pageModelToPage(&item)
+		f(&page)
+		item = PageModel{}
+	}
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+}
+
+func (ps *PageStore) storeMenuEntries(lang string, entries []storedMenuEntry) {
+	b, err := json.Marshal(entries)
+	if err != nil {
+		fmt.Println(err)
+		panic(err)
+	}
+
+	ps.RDBSet(menusKeySpace+"_"+lang, string(b))
+}
+
+func (ps *PageStore) loadMenuEntries(lang string) []storedMenuEntry {
+	var entries []storedMenuEntry
+
+	if b := ps.RDBGet(menusKeySpace + "_" + lang); b != "" {
+		if err := json.Unmarshal([]byte(b), &entries); err != nil {
+			fmt.Println(err)
+			panic(err)
+		}
+	}
+
+	return entries
+}
+
+// loadMenus reads the menus assembled by the last build into .Site.Menus,
+// with the pages of their entries.
+func (s *Site) loadMenus() {
+	entries := s.PageStore.loadMenuEntries(s.Language.Lang)
+
+	var ids PageIds
+	for _, e := range entries {
+		if e.PageId != "" {
+			ids = append(ids, e.PageId)
+		}
+	}
+
+	pages := make(map[PageId]*Page)
+	if len(ids) > 0 {
+		for _, p := range s.PageStore.getPagesById(ids) {
+			pages[PageId(p.ID)] = p
+		}
+	}
+
+	s.Menus = buildMenus(entries, pages)
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildMenus(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	laptops := &Page{ID: "section_laptops"}
+
+	menus := buildMenus([]storedMenuEntry{
+		{Menu: "main", Name: "Home", URL: "/", Weight: 1},
+		{Menu: "main", Identifier: "laptops", Name: "Laptops", URL: "/laptops/", Weight: 2, PageId: "section_laptops"},
+		{Menu: "main", Name: "Lenovo", URL: "/laptops/lenovo/", Weight: 2, Parent: "laptops"},
+		{Menu: "main", Name: "Dell", URL: "/laptops/dell/", Weight: 1, Parent: "laptops"},
+		{Menu: "main", Name: "Tablets", URL: "/tablets/", Parent: "devices"},
+		{Menu: "footer", Name: "About", URL: "/about/"},
+	}, map[PageId]*Page{"section_laptops": laptops})
+
+	assert.Len(menus, 2)
+	assert.Len(*menus["footer"], 1)
+
+	main := *menus["main"]
+	assert.Len(main, 3)
+	assert.Equal("Home", main[0].Name)
+
+	assert.Equal("Laptops", main[1].Name)
+	assert.Equal(laptops, main[1].Page)
+	assert.Len(main[1].Children, 2)
+	assert.Equal("Dell", main[1].Children[0].Name)
+	assert.Equal("Lenovo", main[1].Children[1].Name)
+
+	// A parent without an entry of its own is added without a URL.
+	assert.Equal("devices", main[2].Name)
+	assert.Equal("", main[2].URL)
+	assert.Len(main[2].Children, 1)
+}
+
+func TestMenusFromStore(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", `
+baseURL = "http://example.com/"
+
+[[menu.main]]
+name = "Home"
+url = "/"
+weight = 1
+`)
+	b.WithContent(
+		"products/_index.md", "---\ntitle: Products\nmenu:\n  main:\n    identifier: products\n    weight: 2\n---\n",
+		"products/p1.md", "---\ntitle: P1\nmenu:\n  main:\n    parent: products\n    weight: 2\n---\n",
+		"products/p2.md", "---\ntitle: P2\nmenu:\n  main:\n    parent: products\n    weight: 1\n---\n",
+	)
+	b.WithTemplates(
+		"_default/single.html", `Menu: {{ range .Site.Menus.main }}{{ .Name }}:{{ .URL }}`+
+			`[{{ range .Children }}{{ .Name }}:{{ .URL }}{{ if $.IsMenuCurrent "main" . }}*{{ end }} {{ end }}]`+
+			`{{ if $.HasMenuCurrent "main" . }}+{{ end }} {{ end }}|`,
+		"_default/list.html", `{{ .Title }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/p1/index.html",
+		"Menu: Home:/[] Products:/products/[P2:/products/p2/ P1:/products/p1/* ]+ |")
+}
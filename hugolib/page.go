@@ -101,6 +101,12 @@ type PageModel struct {
 	TranslationKey    string
 	Params            map[string]interface{}
 
+	// The TranslationKey of the page, see materializeTranslations.
+	TranslationGroup string
+
+	// The weight param, for sorting, see storeSortWeight.
+	SortWeight int
+
 	ContentV        template.HTML
 	Summary         template.HTML
 	TableOfContents template.HTML
@@ -160,7 +166,8 @@ type PageModel struct {
 	// menus
 	PageMenus PageMenus
 
-	Position
+	// The neighbours of the page, see storePositions.
+	Position positionRefs `bson:"position,omitempty"`
 
 	GitInfo *gitmap.GitInfo
 
@@ -180,6 +187,22 @@ type PageModel struct {
 	Parent   *Page
 	ParentId PageId `bson:"parentid,omitempty"`
 
+	// Set for the generated facet combination pages.
+	FacetCombination *FacetCombination `bson:"facetcombination,omitempty"`
+
+	// Queried for the sitemap, so it has to be left out when empty.
+	ThinAction string `bson:"thinaction,omitempty"`
+
+	// Also queried for the sitemap.
+	CanonicalURL string `bson:"canonicalurl,omitempty"`
+
+	// Queried to replace the page of a record that moved, see
+	// IngestSourceConfig.ID.
+	RecordKey string `bson:"recordkey,omitempty"`
+
+	// Queried to collapse variants, see markVariantMasters.
+	VariantMaster bool `bson:"variantmaster,omitempty"`
+
 	// When we create paginator pages, we create a copy of the original,
 	// but keep track of it here.
 	OrigOnCopy   *Page
@@ -218,6 +241,11 @@ type PageModel struct {
 	MainPageOutput PageOutput
 
 	PagePath string
+
+	// The content fields above, gzipped, when storeCompressBlobs is set.
+	Blob        []byte `bson:"blob,omitempty"`
+	BlobRawSize int    `bson:"blobrawsize,omitempty"`
+	BlobSize    int    `bson:"blobsize,omitempty"`
 }
 
 type Page struct {
@@ -328,6 +356,7 @@ type Page struct {
 	SourceFileName string
 
 	Position
+	positionRefs positionRefs `bson:"-"`
 
 	GitInfo *gitmap.GitInfo
 
@@ -347,6 +376,26 @@ type Page struct {
 	parent   *Page `bson:"-"`
 	ParentId PageId
 
+	// Set for the generated facet combination pages, see FacetCombination.
+	facetCombination *FacetCombination
+
+	// The refinements of a listing page, see Facets.
+	facets []Facet
+
+	// Set for generated list pages with too few pages, see ThinPagesRule.
+	thinAction string
+
+	// The relative URL of the page this one points search engines to, if
+	// not itself. See CanonicalConfig.
+	canonicalURL string
+
+	// What identifies the record an ingested page is of, see
+	// IngestSourceConfig.ID.
+	recordKey string
+
+	// Whether this is the master of its variants, see markVariantMasters.
+	variantMaster bool
+
 	// When we create paginator pages, we create a copy of the original,
 	// but keep track of it here.
 	origOnCopy   *Page `bson:"-"`
@@ -410,6 +459,19 @@ type Page struct {
 	targetPathDescriptorPrototype *targetPathDescriptor `bson:"-"`
 	saved                         bool
 	pagePath                      string
+
+	// The output format currently being rendered for this page. Set by
+	// pageRenderer, see renderingContext.
+	rc *siteRenderingContext `bson:"-"`
+}
+
+// renderingContext returns the output format this page is being rendered in,
+// falling back to the site's for pages not rendered by pageRenderer.
+func (p *Page) renderingContext() *siteRenderingContext {
+	if p.rc != nil {
+		return p.rc
+	}
+	return p.s.rc
 }
 
 func stackTrace() string {
@@ -562,7 +624,9 @@ type pageInit struct {
 	pageMetaInit        sync.Once
 	pageOutputInit      sync.Once
 	renderingConfigInit sync.Once
+	translationsInit    sync.Once
 	withoutContentInit  sync.Once
+	facetsInit          sync.Once
 }
 
 type pageContentInit struct {
@@ -629,6 +693,14 @@ type PageMeta struct {
 	Weight         int
 }
 
+// Position is the pages before and after a page in the site and in its
+// section. They are read from what is stored with the page, see pageRef, so
+// only their ID, Kind, Title, LinkTitle, Permalink and RelPermalink are
+// set; .Date, .Params, .Summary and the like are zero. Use
+//
+//	{{ with .Next }}{{ ($.Site.GetPageByIdByString .ID).Summary }}{{ end }}
+//
+// or similar to get the full page.
 type Position struct {
 	Prev          *Page
 	Next          *Page
@@ -1200,17 +1272,20 @@ func (p *Page) HasShortcode(name string) bool {
 
 // AllTranslations returns all translations, including the current Page.
 func (p *Page) AllTranslations() Pages {
+	p.initTranslations()
 	return p.translations
 }
 
 // IsTranslated returns whether this content file is translated to
 // other language(s).
 func (p *Page) IsTranslated() bool {
+	p.initTranslations()
 	return len(p.translations) > 1
 }
 
 // Translations returns the translations excluding the current Page.
 func (p *Page) Translations() Pages {
+	p.initTranslations()
 	translations := make(Pages, 0)
 	for _, t := range p.translations {
 		if t.Lang() != p.Lang() {
@@ -2077,9 +2152,22 @@ func (p *Page) prepareData(s *Site) error {
 
 		switch p.Kind {
 		case KindPage:
+			q, found, err := p.collectionQuery()
+			if err != nil {
+				return fmt.Errorf("failed to resolve the query of %q: %s", p.pathOrTitle(), err)
+			}
+			if found {
+				pageIds = s.PageStore.queryPages(q).PageIds()
+			}
 		case KindHome:
 			//pages = s.RegularPages
 		case KindTaxonomy:
+			if c := p.facetCombination; c != nil {
+				p.Data["Combination"] = *c
+				pageIds = s.PageStore.facetCombinationPageIds(*c)
+				break
+			}
+
 			plural := p.sections[0]
 			term := p.sections[1]
 
@@ -2107,10 +2195,10 @@ func (p *Page) prepareData(s *Site) error {
 
 			p.Data["Singular"] = singular
 			p.Data["Plural"] = plural
-			//p.Data["Terms"] = s.Taxonomies[plural]
+			p.Data["Terms"] = s.Info.Taxonomies[plural]
 			// keep the following just for legacy reasons
-			//p.Data["OrderedIndex"] = p.Data["Terms"]
-			//p.Data["Index"] = p.Data["Terms"]
+			p.Data["OrderedIndex"] = p.Data["Terms"]
+			p.Data["Index"] = p.Data["Terms"]
 
 			pageIds = s.PageStore.getPageIdsByTermKey(plural)
 		}
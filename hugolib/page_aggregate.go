@@ -0,0 +1,333 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/spf13/cast"
+)
+
+// PageStats holds the aggregates of a field over a page collection, see
+// StorePages.Stats. Pages without the field are not counted.
+type PageStats struct {
+	Count int
+	Sum   float64
+	Avg   float64
+	Min   interface{}
+	Max   interface{}
+}
+
+// PageGroupCount is the number of pages with a given value of a field.
+type PageGroupCount struct {
+	Key   interface{}
+	Count int
+}
+
+// PageGroupCounts is the result of StorePages.CountBy and
+// StorePages.Histogram. It can be paginated.
+type PageGroupCounts []PageGroupCount
+
+func (p PageGroupCounts) Len() int {
+	return len(p)
+}
+
+// The key of the histogram bucket holding the values outside the boundaries.
+const histogramOtherKey = "other"
+
+// How many ids are written to the ids collection of an aggregation at a
+// time, see aggregate.
+const aggregateIdsBatchSize = 1000
+
+// aggregate runs the stages on the pages of the collection. The field paths
+// in the stages are store fields, see pageQueryField.
+func (sp StorePages) aggregate(result interface{}, stages ...bson.M) {
+	if sp.limit > 0 && sp.byIds() {
+		sp = sp.ps.storePagesByIds(sp.PageIds())
+	}
+
+	c := sp.ps.MongoSession.DB("hugo").C("pages")
+	pipe := []bson.M{{"$match": sp.filter()}}
+
+	if sp.ids != nil {
+		// The ids of a large section do not fit in one $in, so they are
+		// written to a collection of their own to look the pages up from.
+		c = sp.ps.writeAggregateIds(sp.ids)
+		defer c.DropCollection()
+
+		pipe = []bson.M{
+			{"$lookup": bson.M{"from": "pages", "localField": "_id", "foreignField": "_id", "as": "page"}},
+			{"$unwind": "$page"},
+			{"$replaceRoot": bson.M{"newRoot": "$page"}},
+		}
+
+		if len(sp.query) > 0 {
+			pipe = append(pipe, bson.M{"$match": sp.query})
+		}
+	}
+
+	if sp.limit > 0 {
+		pipe = append(pipe, bson.M{"$sort": sortDocument(sp.sort)}, bson.M{"$limit": sp.limit})
+	}
+
+	pipe = append(pipe, stages...)
+
+	if err := c.Pipe(pipe).AllowDiskUse().All(result); err != nil && err != mgo.ErrNotFound {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+}
+
+// writeAggregateIds writes the ids, once each, to a new collection for
+// aggregate, which drops it when done.
+func (ps *PageStore) writeAggregateIds(ids PageIds) *mgo.Collection {
+	c := ps.MongoSession.DB("hugo").C("aggregate_ids_" + bson.NewObjectId().Hex())
+
+	seen := make(map[PageId]bool, len(ids))
+	batch := make([]interface{}, 0, aggregateIdsBatchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		bulk := c.Bulk()
+		bulk.Unordered()
+		bulk.Insert(batch...)
+
+		if _, err := bulk.Run(); err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+
+		batch = batch[:0]
+	}
+
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		batch = append(batch, bson.M{"_id": id})
+		if len(batch) == aggregateIdsBatchSize {
+			flush()
+		}
+	}
+
+	flush()
+
+	return c
+}
+
+// sortDocument turns sort fields as given to mgo's Query.Sort into a
+// $sort stage document.
+func sortDocument(sortFields []string) bson.D {
+	doc := bson.D{}
+	for _, field := range sortFields {
+		order := 1
+		if strings.HasPrefix(field, "-") {
+			field, order = field[1:], -1
+		} else {
+			field = strings.TrimPrefix(field, "+")
+		}
+		doc = append(doc, bson.DocElem{Name: field, Value: order})
+	}
+	return doc
+}
+
+// unwindStage makes one document per value of an array field, so that
+// pages are counted under each of their tags, colors etc.
+func unwindStage(field string) bson.M {
+	return bson.M{"$unwind": "$" + field}
+}
+
+// pageStatsCache holds the Stats of a collection, so that Min, Max, Sum and
+// Avg of the same field aggregate the pages once.
+type pageStatsCache struct {
+	sync.Mutex
+	stats map[string]PageStats
+}
+
+func newPageStatsCache() *pageStatsCache {
+	return &pageStatsCache{stats: make(map[string]PageStats)}
+}
+
+// Stats returns the count, sum, average, minimum and maximum of the field.
+//
+//	{{ with .Data.Pages.Stats "price" }}{{ .Min }} - {{ .Max }}{{ end }}
+func (sp StorePages) Stats(field string) PageStats {
+	if sp.stats == nil {
+		return sp.aggregateStats(field)
+	}
+
+	sp.stats.Lock()
+	defer sp.stats.Unlock()
+
+	stats, found := sp.stats.stats[field]
+	if !found {
+		stats = sp.aggregateStats(field)
+		sp.stats.stats[field] = stats
+	}
+
+	return stats
+}
+
+func (sp StorePages) aggregateStats(field string) PageStats {
+	f := "$" + pageQueryField(field)
+
+	var results []bson.M
+	sp.aggregate(&results, bson.M{"$match": bson.M{pageQueryField(field): bson.M{"$exists": true, "$ne": nil}}}, bson.M{"$group": bson.M{
+		"_id":   nil,
+		"count": bson.M{"$sum": 1},
+		"sum":   bson.M{"$sum": f},
+		"avg":   bson.M{"$avg": f},
+		"min":   bson.M{"$min": f},
+		"max":   bson.M{"$max": f},
+	}})
+
+	if len(results) == 0 {
+		return PageStats{}
+	}
+
+	return PageStats{
+		Count: cast.ToInt(results[0]["count"]),
+		Sum:   cast.ToFloat64(results[0]["sum"]),
+		Avg:   cast.ToFloat64(results[0]["avg"]),
+		Min:   results[0]["min"],
+		Max:   results[0]["max"],
+	}
+}
+
+// Sum returns the sum of the field.
+func (sp StorePages) Sum(field string) float64 {
+	return sp.Stats(field).Sum
+}
+
+// Avg returns the average of the field.
+func (sp StorePages) Avg(field string) float64 {
+	return sp.Stats(field).Avg
+}
+
+// Min returns the smallest value of the field, nil if no page has it.
+func (sp StorePages) Min(field string) interface{} {
+	return sp.Stats(field).Min
+}
+
+// Max returns the largest value of the field, nil if no page has it.
+func (sp StorePages) Max(field string) interface{} {
+	return sp.Stats(field).Max
+}
+
+// Distinct returns the values of the field, in ascending order.
+func (sp StorePages) Distinct(field string) []interface{} {
+	var results []bson.M
+	sp.aggregate(&results,
+		unwindStage(pageQueryField(field)),
+		bson.M{"$group": bson.M{"_id": "$" + pageQueryField(field)}},
+		bson.M{"$sort": bson.M{"_id": 1}})
+
+	values := make([]interface{}, len(results))
+	for i, r := range results {
+		values[i] = r["_id"]
+	}
+
+	return values
+}
+
+// CountBy returns the number of pages per value of the field, most common
+// value first.
+//
+//	{{ range .Data.Pages.CountBy "brand" }}{{ .Key }} ({{ .Count }}){{ end }}
+func (sp StorePages) CountBy(field string) PageGroupCounts {
+	var results []bson.M
+	sp.aggregate(&results,
+		unwindStage(pageQueryField(field)),
+		bson.M{"$group": bson.M{"_id": "$" + pageQueryField(field), "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Name: "count", Value: -1}, {Name: "_id", Value: 1}}})
+
+	return toPageGroupCounts(results)
+}
+
+// Histogram returns the number of pages per bucket of the field. A bucket
+// is keyed by its lower boundary and holds the values up to, but not
+// including, the next boundary. Values outside the boundaries are counted
+// under "other".
+//
+//	{{ range .Data.Pages.Histogram "rating" (slice 1 2 3 4 5 6) }}...{{ end }}
+func (sp StorePages) Histogram(field string, boundaries interface{}) (PageGroupCounts, error) {
+	bounds, err := histogramBoundaries(boundaries)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []bson.M
+	sp.aggregate(&results,
+		bson.M{"$match": bson.M{pageQueryField(field): bson.M{"$exists": true, "$ne": nil}}},
+		bson.M{"$bucket": bson.M{
+			"groupBy":    "$" + pageQueryField(field),
+			"boundaries": bounds,
+			"default":    histogramOtherKey,
+			"output":     bson.M{"count": bson.M{"$sum": 1}},
+		}})
+
+	return toPageGroupCounts(results), nil
+}
+
+func histogramBoundaries(boundaries interface{}) ([]float64, error) {
+	if !isQuerySlice(boundaries) {
+		return nil, fmt.Errorf("histogram boundaries must be a slice, got %T", boundaries)
+	}
+
+	bounds := toQuerySlice(boundaries)
+
+	if len(bounds) < 2 {
+		return nil, errors.New("histogram needs at least two boundaries")
+	}
+
+	var err error
+
+	floats := make([]float64, len(bounds))
+	for i, b := range bounds {
+		if floats[i], err = cast.ToFloat64E(b); err != nil {
+			return nil, fmt.Errorf("histogram boundaries must be numbers: %s", err)
+		}
+	}
+
+	if !sort.Float64sAreSorted(floats) {
+		return nil, errors.New("histogram boundaries must be in ascending order")
+	}
+
+	for i := 1; i < len(floats); i++ {
+		if floats[i] == floats[i-1] {
+			return nil, errors.New("histogram boundaries must be unique")
+		}
+	}
+
+	return floats, nil
+}
+
+func toPageGroupCounts(results []bson.M) PageGroupCounts {
+	counts := make(PageGroupCounts, len(results))
+	for i, r := range results {
+		counts[i] = PageGroupCount{Key: r["_id"], Count: cast.ToInt(r["count"])}
+	}
+	return counts
+}
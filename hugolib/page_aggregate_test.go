@@ -0,0 +1,105 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortDocument(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	assert.Equal(bson.D{
+		{Name: "params.weight", Value: 1},
+		{Name: "pagedates.date", Value: -1},
+		{Name: "title", Value: 1},
+	}, sortDocument([]string{"params.weight", "-pagedates.date", "+title"}))
+	assert.Equal(bson.D{}, sortDocument(nil))
+}
+
+func TestHistogramBoundaries(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	bounds, err := histogramBoundaries([]interface{}{1, "2", 3.5})
+	assert.NoError(err)
+	assert.Equal([]float64{1, 2, 3.5}, bounds)
+
+	for _, invalid := range []interface{}{
+		"1 2 3",
+		[]int{1},
+		[]int{3, 2, 1},
+		[]int{1, 1, 2},
+		[]string{"a", "b"},
+	} {
+		_, err := histogramBoundaries(invalid)
+		assert.Error(err, "%v", invalid)
+	}
+}
+
+func TestPageGroupCountsPagination(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	counts := make(PageGroupCounts, 7)
+	split := splitGroupCounts(counts, 3)
+	assert.Len(split, 3)
+	assert.Equal(1, split[2].Len())
+
+	p, err := newPaginatorFromGroupCounts(counts, 3, nil)
+	assert.NoError(err)
+	assert.Len(p.Pagers()[1].GroupCounts(), 3)
+	assert.Len(p.Pagers()[1].PageIds(), 0)
+}
+
+func TestStatsCache(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	// No store: the stats must come from the cache.
+	sp := StorePages{stats: newPageStatsCache()}
+	sp.stats.stats["price"] = PageStats{Count: 2, Sum: 6, Avg: 3, Min: 1, Max: 5}
+
+	assert.Equal(1, sp.Min("price"))
+	assert.Equal(5, sp.Max("price"))
+	assert.Equal(6.0, sp.Sum("price"))
+	assert.Equal(3.0, sp.Avg("price"))
+
+	assert.True(sp.stats == sp.Reverse().stats)
+	assert.False(sp.stats == sp.Limit(1).stats)
+}
+
+func TestAggregateSectionPages(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithSimpleConfigFile()
+	b.WithContent(
+		"products/p1.md", "---\ntitle: P1\nweight: 1\nprice: 10\nbrand: acme\n---\n",
+		"products/p2.md", "---\ntitle: P2\nweight: 2\nprice: 20\nbrand: acme\n---\n",
+		"products/p3.md", "---\ntitle: P3\nweight: 3\nprice: 30\nbrand: zeta\n---\n",
+	)
+	// The section lists its pages by id.
+	b.WithTemplatesAdded("_default/list.html", `Sum: {{ .Data.Pages.Sum "price" }}|`+
+		`First: {{ (.Data.Pages.ByWeight.Limit 2).Sum "price" }}|`+
+		`Brands: {{ range .Data.Pages.CountBy "brand" }}{{ .Key }}={{ .Count }} {{ end }}`)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/index.html", "Sum: 60|", "First: 30|", "Brands: acme=2 zeta=1 ")
+}
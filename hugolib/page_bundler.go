@@ -46,6 +46,9 @@ type siteContentProcessor struct {
 	// The output Pages
 	pagesChan chan *Page
 
+	// Joins data files to the output Pages, nil if there are none.
+	enricher *pageEnricher
+
 	// Used for partial rebuilds (aka. live reload)
 	// Will signal replacement of pages in the site collection.
 	partialBuild bool
@@ -101,26 +104,44 @@ func (s *siteContentProcessor) closeInput() {
 
 func (s *siteContentProcessor) process(ctx context.Context) error {
 	start_time := time.Now()
+
+	// Not on the contexts of the groups below: g2.Wait cancels them before
+	// the ingester has written what is left in its queue.
+	ingester := s.site.PageStore.newPageIngester(ctx, "pages")
+
 	g1, ctx := errgroup.WithContext(ctx)
 	g2, ctx := errgroup.WithContext(ctx)
 
 	// There can be only one of these per site.
 	g1.Go(func() error {
+		var err error
 		for p := range s.pagesChan {
+			if err != nil {
+				// Keep draining so the content handlers never block.
+				continue
+			}
+
 			if p.s != s.site {
 				panic(fmt.Sprintf("invalid page site: %v vs %v", p.s, s))
 			}
 
+			s.enricher.enrich(p)
+
 			if s.partialBuild {
 				s.site.replacePage(p)
 			} else {
-				//s.site.addPage(p)
-				s.site.PageStore.AddToAllPagesWithBuffer(false, p)
+				err = ingester.Add(p)
 			}
 		}
-		return nil
+		return err
 	})
 
+	if !s.partialBuild {
+		g2.Go(func() error {
+			return s.ingestContent(ctx)
+		})
+	}
+
 	for i := 0; i < s.numWorkers; i++ {
 		g2.Go(func() error {
 			for {
@@ -186,7 +207,8 @@ func (s *siteContentProcessor) process(ctx context.Context) error {
 
 	close(s.pagesChan)
 
-	s.site.PageStore.AddToAllPagesWithBuffer(true, make([]*Page,0)...)
+	err1 := g1.Wait()
+	err2 := ingester.Close()
 
 	fmt.Println("Reading pages to DB took: ", time.Since(start_time))
 
@@ -194,8 +216,12 @@ func (s *siteContentProcessor) process(ctx context.Context) error {
 		return err
 	}
 
-	if err := g1.Wait(); err != nil {
-		return err
+	if err2 != nil {
+		return err2
+	}
+
+	if err1 != nil {
+		return err1
 	}
 
 	s.site.rawAllPages.Sort()
@@ -215,6 +215,10 @@ func (c *contentHandlers) parsePage(h contentHandler) contentHandler {
 			return result
 		}
 
+		if !p.checkCollectionQuery() {
+			return result
+		}
+
 		ctx.currentPage = p
 
 		if ctx.bundle != nil {
@@ -15,10 +15,7 @@ package hugolib
 
 import (
 	"path"
-	"path/filepath"
 	"strings"
-
-	"github.com/gohugoio/hugo/cache"
 )
 
 // PageCollections contains the page collections for a site.
@@ -46,8 +43,6 @@ type PageCollections struct {
 
 	// Includes headless bundles, i.e. bundles that produce no output for its content page.
 	//headlessPages Pages
-
-	pageCache *cache.PartitionedLazyCache
 }
 
 func newPageCollections() *PageCollections {
@@ -58,22 +53,6 @@ func newPageCollectionsFromPages(pages Pages) *PageCollections {
 	return &PageCollections{rawAllPages: pages}
 }
 
-func (c *PageCollections) getPage(typ string, sections ...string) *Page {
-	var key string
-	if len(sections) == 1 {
-		key = filepath.ToSlash(sections[0])
-	} else {
-		key = path.Join(sections...)
-	}
-
-	p, _ := c.pageCache.Get(typ, key)
-	if p == nil {
-		return nil
-	}
-	return p.(*Page)
-
-}
-
 func (*PageCollections) findPagesByKindIn(kind string, inPages Pages) Pages {
 	var pages Pages
 	for _, p := range inPages {
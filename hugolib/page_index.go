@@ -0,0 +1,219 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The RocksDB key spaces of the page indexes, see setPageIndex.
+const (
+	permalinkKeySpace  = "url"
+	targetPathKeySpace = "target"
+	sourcePathKeySpace = "path"
+)
+
+// permalinkEntry is the page output a relative permalink belongs to.
+type permalinkEntry struct {
+	PageId PageId `json:"id"`
+	Format string `json:"format"`
+	Lang   string `json:"lang"`
+}
+
+// pageRefs returns the paths GetPage finds p by: for regular pages the
+// source path and the logical name, for the other kinds the path of their
+// sections, e.g. "blog" or "tags/hugo".
+func pageRefs(p *Page) []string {
+	if p.Kind != KindPage {
+		return []string{path.Join(p.sections...)}
+	}
+
+	if p.Path() == "" {
+		return nil
+	}
+
+	refs := []string{filepath.ToSlash(p.Path())}
+	if name := p.LogicalName(); name != refs[0] {
+		refs = append(refs, name)
+	}
+
+	return refs
+}
+
+func sourcePathKey(lang, kind, ref string) string {
+	return sourcePathKeySpace + "_" + lang + "_" + kind + "_" + strings.TrimPrefix(ref, "/")
+}
+
+// pageIndexKeys returns the keys of the indexes of p, except the permalinks.
+func pageIndexKeys(p *Page) []string {
+	var keys []string
+
+	for _, ref := range pageRefs(p) {
+		keys = append(keys, sourcePathKey(p.Lang(), p.Kind, ref))
+	}
+
+	if p.relTargetPathBase != "" {
+		keys = append(keys, targetPathKeySpace+"_"+p.relTargetPathBase)
+	}
+
+	return keys
+}
+
+// setPageIndex indexes p by its source path, relative target path and
+// relative permalinks, once its paths are set by initPaths. A path shared
+// by more than one page resolves to the last one indexed.
+func (ps *PageStore) setPageIndex(p *Page) {
+	for _, key := range pageIndexKeys(p) {
+		ps.RDBSet(key, p.ID)
+	}
+
+	ps.setPermalinks(p)
+}
+
+// removePageIndex removes the index entries of p that still resolve to it,
+// before it is indexed by its new paths.
+func (ps *PageStore) removePageIndex(p *Page) {
+	for _, key := range pageIndexKeys(p) {
+		if ps.RDBGet(key) == p.ID {
+			ps.RDBDelete(key)
+		}
+	}
+
+	for _, f := range p.OutputFormats() {
+		if entry, found := ps.getPermalink(f.RelPermalink()); found && entry.PageId == PageId(p.ID) {
+			ps.RDBDelete(permalinkKeySpace + "_" + f.RelPermalink())
+		}
+	}
+}
+
+// setPermalinks indexes the relative permalink of each output format of p.
+func (ps *PageStore) setPermalinks(p *Page) {
+	for _, f := range p.OutputFormats() {
+		entry, _ := json.Marshal(permalinkEntry{PageId: PageId(p.ID), Format: f.Name(), Lang: p.Lang()})
+		ps.RDBSet(permalinkKeySpace+"_"+f.RelPermalink(), string(entry))
+	}
+}
+
+// getPermalink returns the page output with the given relative permalink.
+func (ps *PageStore) getPermalink(relPermalink string) (permalinkEntry, bool) {
+	var entry permalinkEntry
+
+	b := ps.RDBGet(permalinkKeySpace + "_" + relPermalink)
+	if b == "" {
+		return entry, false
+	}
+
+	if err := json.Unmarshal([]byte(b), &entry); err != nil {
+		return entry, false
+	}
+
+	return entry, true
+}
+
+// indexedPage returns the page an index entry resolves to, nil if it is
+// gone from the store.
+func (ps *PageStore) indexedPage(pageId PageId) *Page {
+	if pageId == "" || !ps.pageExists(pageId) {
+		return nil
+	}
+	return ps.getPageById(pageId)
+}
+
+// getPageBySourcePath returns the page of the given kind and language with
+// the given path, as described in pageRefs.
+func (ps *PageStore) getPageBySourcePath(lang, kind, ref string) *Page {
+	return ps.indexedPage(PageId(ps.RDBGet(sourcePathKey(lang, kind, ref))))
+}
+
+// getPageByTargetPath returns the page published to the given path relative
+// to the publish dir, without the suffix of its media type.
+func (ps *PageStore) getPageByTargetPath(target string) *Page {
+	return ps.indexedPage(PageId(ps.RDBGet(targetPathKeySpace + "_" + strings.TrimPrefix(target, "/"))))
+}
+
+// getPageByPermalink returns the page with the given relative permalink.
+func (ps *PageStore) getPageByPermalink(relPermalink string) *Page {
+	entry, found := ps.getPermalink(relPermalink)
+	if !found {
+		return nil
+	}
+	return ps.indexedPage(entry.PageId)
+}
+
+// resolveRef returns the regular page a ref or relref points to, looked up
+// by its source path or logical name, then by its target path and then by
+// its relative permalink.
+func (ps *PageStore) resolveRef(lang, ref string) *Page {
+	ref = strings.TrimPrefix(ref, "/")
+
+	if p := ps.getPageBySourcePath(lang, KindPage, ref); p != nil {
+		return p
+	}
+
+	for _, target := range []string{ref, path.Join(ref, "index")} {
+		if p := ps.getPageByTargetPath(target); p != nil {
+			return p
+		}
+	}
+
+	for _, rel := range []string{"/" + ref, "/" + strings.TrimSuffix(ref, "/") + "/"} {
+		if p := ps.getPageByPermalink(rel); p != nil {
+			return p
+		}
+	}
+
+	return nil
+}
+
+func (s *Site) getPage(typ string, sections ...string) *Page {
+	var key string
+	if len(sections) == 1 {
+		key = filepath.ToSlash(sections[0])
+	} else {
+		key = path.Join(sections...)
+	}
+
+	return s.PageStore.getPageBySourcePath(s.Language.Lang, typ, key)
+}
+
+func (s *SiteInfo) getPage(typ string, sections ...string) *Page {
+	return s.s.getPage(typ, sections...)
+}
+
+// GetPageByPermalink returns the page with the given permalink, absolute or
+// relative, nil if there is none.
+//
+//	{{ with .Site.GetPageByPermalink "/laptops/" }}{{ .Title }}{{ end }}
+func (siteInfo *SiteInfo) GetPageByPermalink(permalink string) (*Page, error) {
+	u, err := url.Parse(permalink)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := siteInfo.s.PageStore
+
+	if p := ps.getPageByPermalink(u.Path); p != nil {
+		return p, nil
+	}
+
+	if !strings.HasSuffix(u.Path, "/") {
+		return ps.getPageByPermalink(u.Path + "/"), nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/gohugoio/hugo/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageIndexKeys(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	newIndexedPage := func(kind, target string, sections ...string) *Page {
+		return &Page{
+			Kind:              kind,
+			sections:          sections,
+			relTargetPathBase: target,
+			language:          &helpers.Language{Lang: "en"},
+			pageInit:          &pageInit{},
+		}
+	}
+
+	assert.Equal([]string{"path_en_home_", "target_index"}, pageIndexKeys(newIndexedPage(KindHome, "index")))
+	assert.Equal([]string{"path_en_section_laptops/lenovo", "target_laptops/lenovo/index"},
+		pageIndexKeys(newIndexedPage(KindSection, "laptops/lenovo/index", "laptops", "lenovo")))
+	assert.Equal([]string{"path_en_taxonomy_tags/hugo"}, pageIndexKeys(newIndexedPage(KindTaxonomy, "", "tags", "hugo")))
+
+	assert.Equal("path_en_page_blog/post.md", sourcePathKey("en", KindPage, "/blog/post.md"))
+}
+
+func TestPageIndexBuild(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithSimpleConfigFile()
+	b.WithContent(
+		"blog/post.md", "---\ntitle: Post\nurl: /articles/post/\n---\n",
+		"blog/_index.md", "---\ntitle: Blog\n---\n",
+		"about.md", "---\ntitle: About\n---\nSee [the post]({{< relref \"blog/post.md\" >}}).",
+	)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}|{{ .Content }}`,
+		"_default/list.html", `{{ .Title }}`,
+		"index.html", `Permalink: {{ with .Site.GetPageByPermalink "http://example.com/articles/post/" }}{{ .Title }}{{ end }}|`+
+			`NoSlash: {{ with .Site.GetPageByPermalink "/articles/post" }}{{ .Title }}{{ end }}|`+
+			`Section: {{ with .Site.GetPage "section" "blog" }}{{ .Title }}{{ end }}|`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/index.html", "Permalink: Post|", "NoSlash: Post|", "Section: Blog|")
+	b.AssertFileContent("public/about/index.html", `href="/articles/post/"`)
+}
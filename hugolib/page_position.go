@@ -0,0 +1,187 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// How many positions are written to RocksDB at a time.
+const positionsBatchSize = 1000
+
+// pageRef is a page next to another in a listing, stored with it so that
+// Prev, Next and the like can be rendered without reading the page.
+type pageRef struct {
+	ID           string `bson:"_id" json:"id"`
+	Kind         string `bson:"kind" json:"kind"`
+	Title        string `bson:"title" json:"title,omitempty"`
+	LinkTitle    string `bson:"linktitle" json:"linkTitle,omitempty"`
+	Permalink    string `bson:"permalink" json:"permalink,omitempty"`
+	RelPermalink string `bson:"relpermalink" json:"relPermalink,omitempty"`
+
+	// Only used to split the section listings.
+	ParentId PageId `bson:"parentid" json:"-"`
+}
+
+// positionRefs is the Position of a page as stored with it.
+type positionRefs struct {
+	Prev          *pageRef `bson:"prev,omitempty" json:"prev,omitempty"`
+	Next          *pageRef `bson:"next,omitempty" json:"next,omitempty"`
+	PrevInSection *pageRef `bson:"previnsection,omitempty" json:"prevInSection,omitempty"`
+	NextInSection *pageRef `bson:"nextinsection,omitempty" json:"nextInSection,omitempty"`
+}
+
+// page returns a page with what a pageRef has of it: its id, kind, titles
+// and permalinks.
+func (r *pageRef) page(ps *PageStore) *Page {
+	if r == nil {
+		return nil
+	}
+
+	return &Page{
+		ID:              r.ID,
+		Kind:            r.Kind,
+		title:           r.Title,
+		linkTitle:       r.LinkTitle,
+		permalink:       r.Permalink,
+		relPermalink:    r.RelPermalink,
+		pageInit:        &pageInit{},
+		pageContentInit: &pageContentInit{},
+		s:               ps.Site,
+		Site:            ps.SiteInfo,
+	}
+}
+
+func (r positionRefs) position(ps *PageStore) Position {
+	return Position{
+		Prev:          r.Prev.page(ps),
+		Next:          r.Next.page(ps),
+		PrevInSection: r.PrevInSection.page(ps),
+		NextInSection: r.NextInSection.page(ps),
+	}
+}
+
+// positionWindow walks a sorted listing and calls set for each page with
+// the pages before and after it, nil at the ends. With group set, the
+// listing is split where it changes.
+type positionWindow struct {
+	group func(r *pageRef) PageId
+	set   func(prev, cur, next *pageRef)
+
+	prev, cur *pageRef
+}
+
+func (w *positionWindow) push(r *pageRef) {
+	if w.cur != nil && w.group != nil && w.group(w.cur) != w.group(r) {
+		w.flush()
+	}
+
+	if w.cur != nil {
+		w.set(w.prev, w.cur, r)
+		w.prev = w.cur
+	}
+
+	w.cur = r
+}
+
+func (w *positionWindow) flush() {
+	if w.cur != nil {
+		w.set(w.prev, w.cur, nil)
+	}
+	w.prev, w.cur = nil, nil
+}
+
+// storePositions computes the neighbours of the regular pages of the
+// language, in the default sort of the site and of their sections, and
+// writes them to RocksDB in batches for preparePages to store with the
+// pages, see loadPosition.
+func (ps *PageStore) storePositions(lang string) {
+	batch := make(map[string]string)
+
+	write := func(key string, refs positionRefs) {
+		b, _ := json.Marshal(refs)
+		batch[key] = string(b)
+		if len(batch) >= positionsBatchSize {
+			ps.RDBMSet(batch)
+			batch = make(map[string]string)
+		}
+	}
+
+	// The site listing, as setupSitePages did.
+	ps.eachPositionRef(lang, defaultStorePagesSort, &positionWindow{
+		set: func(prev, cur, next *pageRef) {
+			write(cur.ID+"_Position", positionRefs{Prev: prev, Next: next})
+		},
+	})
+
+	// The listings of the sections, newest first.
+	ps.eachPositionRef(lang, append([]string{"parentid"}, defaultStorePagesSort...), &positionWindow{
+		group: func(r *pageRef) PageId { return r.ParentId },
+		set: func(prev, cur, next *pageRef) {
+			write(cur.ID+"_SectionPosition", positionRefs{PrevInSection: next, NextInSection: prev})
+		},
+	})
+
+	ps.RDBMSet(batch)
+}
+
+func (ps *PageStore) eachPositionRef(lang string, sortFields []string, w *positionWindow) {
+	pipe := []bson.M{
+		{"$match": bson.M{"lang": lang, "kind": KindPage}},
+		{"$sort": sortDocument(sortFields)},
+		{"$project": bson.M{"kind": 1, "title": 1, "linktitle": 1, "permalink": 1, "relpermalink": 1, "parentid": 1}},
+	}
+
+	items := ps.MongoSession.DB("hugo").C("pages").Pipe(pipe).AllowDiskUse().Batch(positionsBatchSize).Iter()
+
+	for {
+		r := &pageRef{}
+		if !items.Next(r) {
+			break
+		}
+		w.push(r)
+	}
+
+	w.flush()
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+}
+
+// loadPosition sets the neighbours computed by storePositions on p.
+func (ps *PageStore) loadPosition(p *Page) {
+	p.positionRefs = positionRefs{}
+
+	if p.Kind != KindPage {
+		return
+	}
+
+	values := ps.RDBMGet(p.ID+"_Position", p.ID+"_SectionPosition")
+
+	var site, section positionRefs
+	json.Unmarshal([]byte(values[0]), &site)
+	json.Unmarshal([]byte(values[1]), &section)
+
+	p.positionRefs = positionRefs{
+		Prev:          site.Prev,
+		Next:          site.Next,
+		PrevInSection: section.PrevInSection,
+		NextInSection: section.NextInSection,
+	}
+}
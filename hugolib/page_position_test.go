@@ -0,0 +1,86 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPositionWindow(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	idOf := func(r *pageRef) string {
+		if r == nil {
+			return ""
+		}
+		return r.ID
+	}
+
+	walk := func(group func(r *pageRef) PageId, refs ...*pageRef) map[string][2]string {
+		got := make(map[string][2]string)
+		w := &positionWindow{
+			group: group,
+			set: func(prev, cur, next *pageRef) {
+				got[cur.ID] = [2]string{idOf(prev), idOf(next)}
+			},
+		}
+		for _, r := range refs {
+			w.push(r)
+		}
+		w.flush()
+		return got
+	}
+
+	a, b, c := &pageRef{ID: "a", ParentId: "s1"}, &pageRef{ID: "b", ParentId: "s1"}, &pageRef{ID: "c", ParentId: "s2"}
+
+	assert.Equal(map[string][2]string{
+		"a": {"", "b"},
+		"b": {"a", "c"},
+		"c": {"b", ""},
+	}, walk(nil, a, b, c))
+
+	assert.Equal(map[string][2]string{
+		"a": {"", "b"},
+		"b": {"a", ""},
+		"c": {"", ""},
+	}, walk(func(r *pageRef) PageId { return r.ParentId }, a, b, c))
+
+	assert.Empty(walk(nil))
+}
+
+func TestPositionFromStore(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithSimpleConfigFile()
+	// Unweighted pages go last.
+	b.WithContent(
+		"a.md", "---\ntitle: A\nweight: 1\n---\n",
+		"b.md", "---\ntitle: B\nweight: 2\n---\n",
+		"c.md", "---\ntitle: C\n---\n",
+	)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}|Prev: {{ with .Prev }}{{ .Title }} {{ .RelPermalink }}{{ end }}|Next: {{ with .Next }}{{ .Title }}{{ end }}|`,
+		"_default/list.html", `{{ .Title }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/a/index.html", "A|Prev: |Next: B|")
+	b.AssertFileContent("public/b/index.html", "B|Prev: A /a/|Next: C|")
+	b.AssertFileContent("public/c/index.html", "C|Prev: B /b/|Next: |")
+}
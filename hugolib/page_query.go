@@ -0,0 +1,451 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/spf13/cast"
+)
+
+// A PageQuery selects pages from the page store. It is what .Site.Query
+// builds from its dict argument, e.g.
+//
+//	{{ $laptops := .Site.Query (dict
+//		"kind" "page"
+//		"section" "laptops"
+//		"filter" (dict "brand" (slice "dell" "lenovo") "price" (dict "gte" 300 "lt" 800) "refurbished" (dict "exists" false))
+//		"sort" (slice "-price" "title")
+//		"limit" 20) }}
+//
+// The result is a StorePages, so it can be ranged over, paginated, or
+// projected with .PageIds and .LitePages. Use .Explain to see what the
+// store makes of it.
+type PageQuery struct {
+	Conditions []PageQueryCondition
+	Sort       []PageQuerySort
+	Limit      int
+}
+
+// A PageQueryCondition is one filter of a PageQuery. Field is a page field
+// such as "kind", "section" or "date", or else the name of a page param.
+type PageQueryCondition struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+// PageQuerySort is one sort key of a PageQuery.
+type PageQuerySort struct {
+	Field string
+	Desc  bool
+}
+
+// The page fields that can be filtered and sorted on. Any other field is
+// looked up in the page params. Weight is the stored sort weight, so pages
+// without one sort last, as in DefaultPageSort, and filters see their
+// weight as greater than any other.
+var pageQueryFields = map[string]string{
+	"id":          "_id",
+	"kind":        "kind",
+	"section":     "sections.0",
+	"lang":        "lang",
+	"title":       "title",
+	"linktitle":   "linktitle",
+	"date":        "pagedates.date",
+	"publishdate": "pagedates.publishdate",
+	"lastmod":     "pagedates.lastmod",
+	"expirydate":  "pagedates.expirydate",
+	"weight":      "sortweight",
+	"draft":       "draft",
+}
+
+var pageQueryOps = map[string]string{
+	"eq":     "$eq",
+	"ne":     "$ne",
+	"in":     "$in",
+	"nin":    "$nin",
+	"gt":     "$gt",
+	"gte":    "$gte",
+	"lt":     "$lt",
+	"lte":    "$lte",
+	"exists": "$exists",
+}
+
+// The keys of the .Site.Query dict that are shorthands for an eq condition.
+var pageQueryShorthands = []string{"kind", "section", "lang"}
+
+// parsePageQuery reads a PageQuery from the dict given to .Site.Query.
+// Unless the dict says otherwise with "lang", only pages in lang are
+// selected; "lang" "*" selects pages in all languages.
+func parsePageQuery(spec map[string]interface{}, lang string) (PageQuery, error) {
+	q := PageQuery{}
+
+	for key := range spec {
+		switch strings.ToLower(key) {
+		case "kind", "section", "lang", "filter", "sort", "limit":
+		default:
+			return q, fmt.Errorf("unknown query key %q", key)
+		}
+	}
+
+	spec = lowerKeys(spec)
+
+	if _, found := spec["lang"]; !found && lang != "" {
+		spec["lang"] = lang
+	}
+
+	for _, key := range pageQueryShorthands {
+		v, found := spec[key]
+		if !found || v == "*" {
+			continue
+		}
+
+		conds, err := parsePageQueryConditions(key, v)
+		if err != nil {
+			return q, err
+		}
+		q.Conditions = append(q.Conditions, conds...)
+	}
+
+	if filter, found := spec["filter"]; found {
+		m, err := cast.ToStringMapE(filter)
+		if err != nil {
+			return q, fmt.Errorf("query filter must be a dict: %s", err)
+		}
+
+		// Keep the conditions, and so Explain, stable.
+		fields := make([]string, 0, len(m))
+		for field := range m {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			conds, err := parsePageQueryConditions(field, m[field])
+			if err != nil {
+				return q, err
+			}
+			q.Conditions = append(q.Conditions, conds...)
+		}
+	}
+
+	if s, found := spec["sort"]; found {
+		var keys []string
+		if str, ok := s.(string); ok {
+			keys = []string{str}
+		} else {
+			var err error
+			keys, err = cast.ToStringSliceE(s)
+			if err != nil {
+				return q, fmt.Errorf("query sort must be a string or a slice of strings: %s", err)
+			}
+		}
+
+		for _, key := range keys {
+			qs := PageQuerySort{Field: key}
+			if strings.HasPrefix(key, "-") {
+				qs = PageQuerySort{Field: key[1:], Desc: true}
+			}
+			q.Sort = append(q.Sort, qs)
+		}
+	}
+
+	if l, found := spec["limit"]; found {
+		limit, err := cast.ToIntE(l)
+		if err != nil || limit < 0 {
+			return q, fmt.Errorf("query limit must be a positive number, got %v", l)
+		}
+		q.Limit = limit
+	}
+
+	return q, nil
+}
+
+// parsePageQueryConditions reads the conditions on one field. A dict holds
+// operators and their values, a slice means in and anything else means eq.
+func parsePageQueryConditions(field string, v interface{}) ([]PageQueryCondition, error) {
+	// YAML front matter gives map[interface{}]interface{}.
+	if m, ok := v.(map[interface{}]interface{}); ok {
+		v = cast.ToStringMap(m)
+	}
+
+	if m, ok := v.(map[string]interface{}); ok {
+		ops := make([]string, 0, len(m))
+		for op := range m {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+
+		conds := make([]PageQueryCondition, 0, len(m))
+
+		for _, op := range ops {
+			lop := strings.ToLower(op)
+			if _, found := pageQueryOps[lop]; !found {
+				return nil, fmt.Errorf("unknown query operator %q for %q", op, field)
+			}
+
+			value := m[op]
+
+			if lop == "in" || lop == "nin" {
+				value = toQuerySlice(value)
+			}
+
+			if lop == "exists" {
+				b, err := cast.ToBoolE(value)
+				if err != nil {
+					return nil, fmt.Errorf("exists for %q must be true or false", field)
+				}
+				value = b
+			}
+
+			conds = append(conds, PageQueryCondition{Field: field, Op: lop, Value: value})
+		}
+
+		return conds, nil
+	}
+
+	if isQuerySlice(v) {
+		return []PageQueryCondition{{Field: field, Op: "in", Value: toQuerySlice(v)}}, nil
+	}
+
+	return []PageQueryCondition{{Field: field, Op: "eq", Value: v}}, nil
+}
+
+func isQuerySlice(v interface{}) bool {
+	switch v.(type) {
+	case []interface{}, []string, []int, []float64:
+		return true
+	}
+	return false
+}
+
+func toQuerySlice(v interface{}) []interface{} {
+	switch vv := v.(type) {
+	case []interface{}:
+		return vv
+	case []string:
+		s := make([]interface{}, len(vv))
+		for i, x := range vv {
+			s[i] = x
+		}
+		return s
+	case []int:
+		s := make([]interface{}, len(vv))
+		for i, x := range vv {
+			s[i] = x
+		}
+		return s
+	case []float64:
+		s := make([]interface{}, len(vv))
+		for i, x := range vv {
+			s[i] = x
+		}
+		return s
+	}
+	return []interface{}{v}
+}
+
+func lowerKeys(m map[string]interface{}) map[string]interface{} {
+	lm := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		lm[strings.ToLower(k)] = v
+	}
+	return lm
+}
+
+// pageQueryField returns the store field for a query field.
+func pageQueryField(field string) string {
+	lfield := strings.ToLower(field)
+
+	if f, found := pageQueryFields[lfield]; found {
+		return f
+	}
+
+	// Store fields, e.g. "pagedates.date", are taken as is.
+	for _, f := range pageQueryFields {
+		if lfield == f {
+			return f
+		}
+	}
+
+	if strings.HasPrefix(lfield, "params.") {
+		return lfield
+	}
+
+	return "params." + lfield
+}
+
+// compile turns the query into the filter and sort of the pages collection.
+func (q PageQuery) compile() (bson.M, []string) {
+	filter := bson.M{}
+
+	for _, c := range q.Conditions {
+		field := pageQueryField(c.Field)
+
+		ops, _ := filter[field].(bson.M)
+		if ops == nil {
+			ops = bson.M{}
+			filter[field] = ops
+		}
+
+		ops[pageQueryOps[c.Op]] = c.Value
+	}
+
+	var sortFields []string
+
+	for _, s := range q.Sort {
+		field := pageQueryField(s.Field)
+		if s.Desc {
+			field = "-" + field
+		}
+		sortFields = append(sortFields, field)
+	}
+
+	return filter, sortFields
+}
+
+func (ps *PageStore) queryPages(q PageQuery) StorePages {
+	filter, sortFields := q.compile()
+
+	sp := ps.storePages(filter, sortFields...)
+	if q.Limit > 0 {
+		sp = sp.Limit(q.Limit)
+	}
+
+	return sp
+}
+
+// Query selects pages in the current language from the page store, see
+// PageQuery.
+func (siteInfo *SiteInfo) Query(spec map[string]interface{}) (StorePages, error) {
+	q, err := parsePageQuery(spec, siteInfo.s.Language.Lang)
+	if err != nil {
+		return StorePages{}, fmt.Errorf("Query: %s", err)
+	}
+
+	return siteInfo.s.PageStore.queryPages(q), nil
+}
+
+// LitePages returns the LitePages of the collection, in order.
+func (sp StorePages) LitePages() []LitePage {
+	return sp.ps.getLitePagesById(sp.PageIds())
+}
+
+// Count returns the number of pages in the collection.
+func (sp StorePages) Count() int {
+	return sp.Len()
+}
+
+// Explain returns, as JSON, the filter and sort the collection is read
+// with and the plan the store picked for it.
+func (sp StorePages) Explain() string {
+	explain := struct {
+		Filter interface{} `json:"filter"`
+		Sort   []string    `json:"sort"`
+		Limit  int         `json:"limit,omitempty"`
+		Plan   interface{} `json:"plan"`
+	}{
+		Filter: sp.filter(),
+		Sort:   sp.sort,
+		Limit:  sp.limit,
+	}
+
+	plan := bson.M{}
+	if err := sp.find().Explain(&plan); err != nil {
+		explain.Plan = err.Error()
+	} else if qp, ok := plan["queryPlanner"].(bson.M); ok {
+		explain.Plan = bson.M{"winningPlan": qp["winningPlan"], "rejectedPlans": len(cast.ToSlice(qp["rejectedPlans"]))}
+	} else {
+		explain.Plan = plan
+	}
+
+	b, err := json.MarshalIndent(explain, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(b)
+}
+
+// collectionQuery returns the query declared with "query" in the front
+// matter of a content page, which makes the page list the matching pages
+// like a section does, e.g.
+//
+//	query:
+//	  section: laptops
+//	  filter:
+//	    price: {lt: 100}
+//	  sort: -price
+//	  limit: 500
+//
+// The query selects regular pages in the language of p unless it says
+// otherwise, and never p itself.
+func (p *Page) collectionQuery() (PageQuery, bool, error) {
+	if p.Kind != KindPage {
+		return PageQuery{}, false, nil
+	}
+
+	v, found := p.params["query"]
+	if !found {
+		return PageQuery{}, false, nil
+	}
+
+	spec, err := cast.ToStringMapE(v)
+	if err != nil {
+		return PageQuery{}, true, fmt.Errorf("query must be a map: %s", err)
+	}
+
+	spec = lowerKeys(spec)
+	if _, found := spec["kind"]; !found {
+		spec["kind"] = KindPage
+	}
+
+	q, err := parsePageQuery(spec, p.Lang())
+	if err != nil {
+		return q, true, err
+	}
+
+	q.Conditions = append(q.Conditions, PageQueryCondition{Field: "id", Op: "ne", Value: p.ID})
+
+	return q, true, nil
+}
+
+// checkCollectionQuery warns about a malformed query in the front
+// matter of p and returns false, in which case p is left out of the build
+// rather than failing it.
+func (p *Page) checkCollectionQuery() bool {
+	if _, _, err := p.collectionQuery(); err != nil {
+		p.s.Log.WARN.Printf("Skipping %q: %s", p.pathOrTitle(), err)
+		return false
+	}
+	return true
+}
+
+// hasCollectionQuery returns whether p is a content page with a query, see
+// collectionQuery.
+func (p *Page) hasCollectionQuery() bool {
+	_, found, _ := p.collectionQuery()
+	return found
+}
+
+// listsPages returns whether p lists other pages, i.e. has .Data.Pages and
+// can be paginated.
+func (p *Page) listsPages() bool {
+	return p.IsNode() || p.hasCollectionQuery()
+}
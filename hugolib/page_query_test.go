@@ -0,0 +1,101 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePageQuery(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	q, err := parsePageQuery(map[string]interface{}{
+		"kind":    "page",
+		"Section": "laptops",
+		"filter": map[string]interface{}{
+			"Brand": []string{"dell", "lenovo"},
+			"price": map[string]interface{}{"gte": 300, "lt": 800},
+			"sale":  map[string]interface{}{"exists": "true"},
+			"color": "red",
+		},
+		"sort":  []interface{}{"-price", "title"},
+		"limit": "20",
+	}, "en")
+	assert.NoError(err)
+
+	filter, sort := q.compile()
+	assert.Equal(bson.M{
+		"kind":         bson.M{"$eq": "page"},
+		"sections.0":   bson.M{"$eq": "laptops"},
+		"lang":         bson.M{"$eq": "en"},
+		"params.brand": bson.M{"$in": []interface{}{"dell", "lenovo"}},
+		"params.price": bson.M{"$gte": 300, "$lt": 800},
+		"params.sale":  bson.M{"$exists": true},
+		"params.color": bson.M{"$eq": "red"},
+	}, filter)
+	assert.Equal([]string{"-params.price", "title"}, sort)
+	assert.Equal(20, q.Limit)
+
+	q, err = parsePageQuery(map[string]interface{}{"lang": "*", "sort": "-pagedates.date"}, "en")
+	assert.NoError(err)
+	filter, sort = q.compile()
+	assert.Equal(bson.M{}, filter)
+	assert.Equal([]string{"-pagedates.date"}, sort)
+
+	q, err = parsePageQuery(map[string]interface{}{"sort": []interface{}{"weight", "-date"}}, "en")
+	assert.NoError(err)
+	_, sort = q.compile()
+	assert.Equal([]string{"sortweight", "-pagedates.date"}, sort)
+
+	// As read from YAML front matter.
+	q, err = parsePageQuery(map[string]interface{}{
+		"filter": map[interface{}]interface{}{
+			"price": map[interface{}]interface{}{"lt": 100},
+		},
+	}, "en")
+	assert.NoError(err)
+	filter, _ = q.compile()
+	assert.Equal(bson.M{"$lt": 100}, filter["params.price"])
+
+	_, err = parsePageQuery(map[string]interface{}{"where": "x"}, "en")
+	assert.Error(err)
+	_, err = parsePageQuery(map[string]interface{}{"filter": map[string]interface{}{"price": map[string]interface{}{"like": 1}}}, "en")
+	assert.Error(err)
+	_, err = parsePageQuery(map[string]interface{}{"limit": -1}, "en")
+	assert.Error(err)
+}
+
+func TestCollectionQueryNotADict(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithSimpleConfigFile()
+	b.WithContent(
+		"laptops/l1.md", "---\ntitle: L1\n---\n",
+		"deals.md", "---\ntitle: Deals\nquery:\n  section: laptops\n---\n",
+		"broken.md", "---\ntitle: Broken\nquery: laptops\n---\n",
+	)
+	b.WithTemplatesAdded("_default/single.html", `{{ .Title }}:{{ range .Data.Pages }}{{ .Title }}{{ end }}`)
+
+	// The page with the malformed query is skipped with a warning, which
+	// does not fail the build.
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/deals/index.html", "Deals:L1")
+	require.False(t, b.CheckExists("public/broken/index.html"))
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/go-redis/redis"
 	"github.com/gohugoio/hugo/config"
 	"github.com/patrickmn/go-cache"
+	"github.com/spf13/cast"
 	"github.com/tecbot/gorocksdb"
 	"html/template"
 	"log"
@@ -60,7 +61,7 @@ type PageStore struct {
 
 	SinceTime time.Time
 
-	PagesQueue []*Page
+	compressBlobs bool
 
 	tempPages       NewPages
 	tempRawPages    NewPages
@@ -69,7 +70,7 @@ type PageStore struct {
 	updateMutex     *sync.Mutex
 }
 
-func (ps *PageStore) initPageStore(site *Site) {
+func (ps *PageStore) initPageStore(site *Site) error {
 
 	url := "mongodb://localhost"
 
@@ -107,6 +108,16 @@ func (ps *PageStore) initPageStore(site *Site) {
 	handler := text.New(fi)
 	apex.SetHandler(handler)
 
+	// Checked before the store is reset, so a typo does not cost it.
+	var compression *gorocksdb.CompressionType
+	if name := ps.Cfg.GetString("storeCompression"); name != "" {
+		c, err := rocksDbCompression(name)
+		if err != nil {
+			return err
+		}
+		compression = &c
+	}
+
 	noReset := ps.Cfg.GetBool("noReset")
 
 	ps.Redis = redis.NewClient(&redis.Options{
@@ -123,6 +134,9 @@ func (ps *PageStore) initPageStore(site *Site) {
 		ps.MongoSession.DB("hugo").C("pages_temp").DropCollection()
 		ps.MongoSession.DB("hugo").C("raw_pages").DropCollection()
 		ps.MongoSession.DB("hugo").C("weighted_pages").DropCollection()
+		ps.MongoSession.DB("hugo").C("taxonomy_terms").DropCollection()
+		ps.MongoSession.DB("hugo").C("store_meta").DropCollection()
+		ps.MongoSession.DB("hugo").C("translations").DropCollection()
 
 		ps.CreateWeightedPagesIndesx()
 
@@ -134,6 +148,12 @@ func (ps *PageStore) initPageStore(site *Site) {
 
 	}
 
+	if err := ps.initStoreSchema(noReset); err != nil {
+		return err
+	}
+
+	ps.ensurePagesIndexes()
+
 	bbto := gorocksdb.NewDefaultBlockBasedTableOptions()
 	lruCache := gorocksdb.NewLRUCache(1024 * 1024 * 100)
 
@@ -143,6 +163,11 @@ func (ps *PageStore) initPageStore(site *Site) {
 	opts := gorocksdb.NewDefaultOptions()
 	opts.SetBlockBasedTableFactory(bbto)
 	opts.SetCreateIfMissing(true)
+	if compression != nil {
+		opts.SetCompression(*compression)
+	}
+
+	ps.compressBlobs = ps.Cfg.GetBool("storeCompressBlobs")
 
 	db, err := gorocksdb.OpenDb(opts, dbPath)
 
@@ -157,7 +182,7 @@ func (ps *PageStore) initPageStore(site *Site) {
 
 	ps.RocksDb = db
 
-	ps.PagesQueue = make([]*Page, 0)
+	return nil
 }
 
 func (ps *PageStore) CreateWeightedPagesIndesx() {
@@ -175,6 +200,53 @@ func (ps *PageStore) CreateWeightedPagesIndesx() {
 		fmt.Println(err.Error())
 		panic(err)
 	}
+
+	index2 := mgo.Index{
+		Key:        []string{"plural", "key", "weight", "-date", "title"},
+		Unique:     false,
+		DropDups:   false,
+		Background: true,
+		Sparse:     false,
+	}
+
+	err = ps.MongoSession.DB("hugo").C("weighted_pages").EnsureIndex(index2)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	// For the facet counts, which join the weighted pages on page id.
+	index3 := mgo.Index{
+		Key:        []string{"pageid", "plural"},
+		Unique:     false,
+		DropDups:   false,
+		Background: true,
+		Sparse:     false,
+	}
+
+	err = ps.MongoSession.DB("hugo").C("weighted_pages").EnsureIndex(index3)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	// For the facet combinations, which are per section.
+	index4 := mgo.Index{
+		Key:        []string{"section", "plural", "key"},
+		Unique:     false,
+		DropDups:   false,
+		Background: true,
+		Sparse:     false,
+	}
+
+	err = ps.MongoSession.DB("hugo").C("weighted_pages").EnsureIndex(index4)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
 }
 func (ps *PageStore) CreateMongoIndex() {
 	index3 := mgo.Index{
@@ -195,6 +267,64 @@ func (ps *PageStore) CreateMongoIndex() {
 		panic(err3)
 	}
 
+	ps.ensurePagesIndexes()
+}
+
+// ensurePagesIndexes creates the indexes that pages are queried by while
+// rendering. The pages collection loses them whenever it is replaced by
+// pages_temp or has its indexes dropped, so this is called after each.
+func (ps *PageStore) ensurePagesIndexes() {
+	if ps.Cfg.GetBool("noMongoIndex") {
+		return
+	}
+
+	// EnsureIndex skips indexes it has created before on the session,
+	// even if they are gone since.
+	ps.MongoSession.ResetIndexCache()
+
+	indexes := []mgo.Index{
+		// For StorePages and their pagers: .Data.Pages of sections and of
+		// the home page, in defaultStorePagesSort.
+		{
+			Key:        []string{"lang", "kind", "parentid", "sortweight", "-pagedates.date", "title"},
+			Background: true,
+		},
+		{
+			Key:        []string{"lang", "kind", "sortweight", "-pagedates.date", "title"},
+			Background: true,
+		},
+	}
+
+	// For the page of an ingested record, see IngestSourceConfig.ID.
+	indexes = append(indexes, mgo.Index{
+		Key:        []string{"recordkey"},
+		Background: true,
+		Sparse:     true,
+	})
+
+	// For GetPageBy, see decodeLookupKeys.
+	for _, key := range ps.Site.lookupKeys {
+		indexes = append(indexes, mgo.Index{
+			Key:        []string{"params." + key},
+			Background: true,
+			Sparse:     true,
+		})
+	}
+
+	// For the variants of a page, see VariantsConfig.
+	if variants := ps.Site.variants; variants.enabled() {
+		indexes = append(indexes, mgo.Index{
+			Key:        []string{"lang", variants.groupField(), "variantmaster"},
+			Background: true,
+		})
+	}
+
+	for _, index := range indexes {
+		if err := ps.MongoSession.DB("hugo").C("pages").EnsureIndex(index); err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}
 }
 
 func (ps *PageStore) CreateSectionsIndex() {
@@ -216,6 +346,7 @@ func (ps *PageStore) CreateSectionsIndex() {
 		panic(err)
 	}
 
+	ps.ensurePagesIndexes()
 }
 
 type NewPages []*Page
@@ -259,15 +390,6 @@ func (ps *PageStore) AddToAllRawrPages(pages ...*Page) {
 
 }
 
-func (ps *PageStore) AddToAllPagesWithBuffer(flush bool, pages ...*Page) {
-	ps.PagesQueue = append(ps.PagesQueue, pages...)
-
-	if len(ps.PagesQueue) >= 500 || flush {
-		ps.AddToAllPages(ps.PagesQueue...)
-		ps.PagesQueue = ps.PagesQueue[:0]
-	}
-}
-
 func (ps *PageStore) AddToAllPages(pages ...*Page) {
 
 	var dataSlice = pages
@@ -343,22 +465,18 @@ func (ps *PageStore) AddWeightedPageIds(plural, key string, pws ...WeightedPage)
 	for i, p := range dataSlice {
 		id := fmt.Sprint(plural, "_", key, "_", p.ID)
 		wp := WeightedPageIds{
-			ID:     id,
-			Weight: p.Weight,
-			Key:    key,
-			PageId: PageId(p.ID),
-			Plural: plural,
+			ID:      id,
+			Weight:  p.Weight,
+			Key:     key,
+			PageId:  PageId(p.ID),
+			Plural:  plural,
+			Lang:    p.Lang(),
+			Section: p.Section(),
+			Date:    p.Date,
+			Title:   p.title,
 			//Params: p.params,
 		}
 
-		//if plural == "searches" {
-		//	terms := strings.Split(key, "--")
-		//	searchKeysArray := terms[1:]
-		//	wp.SearchKeys = searchKeysArray
-		//	wp.Cardinality = len(searchKeysArray)
-		//	wp.SearchLabel = terms[0]
-		//}
-
 		interfaceSlice[i] = wp
 
 	}
@@ -460,6 +578,7 @@ func (ps *PageStore) eachPages(f func(*Page) (error), update bool, loadPageIds b
 			panic(err)
 		}
 
+		ps.ensurePagesIndexes()
 	}
 
 	elapsed := time.Since(start)
@@ -518,6 +637,8 @@ func (ps *PageStore) eachPagesWithSort(f func(*Page) (error), update bool) {
 			fmt.Println(err.Error())
 			panic(err)
 		}
+
+		ps.ensurePagesIndexes()
 	}
 
 	elapsed := time.Since(start)
@@ -654,7 +775,7 @@ func (ps *PageStore) getPageIdsByTermKey(plural string) PageIds {
 func (ps *PageStore) getPageIdsByTaxonomyKey(plural string, term string) PageIds {
 	item := WeightedPageIds{}
 
-	items := ps.MongoSession.DB("hugo").C("weighted_pages").Find(bson.M{"plural": plural, "key": term}).Batch(1000).Iter()
+	items := ps.MongoSession.DB("hugo").C("weighted_pages").Find(bson.M{"plural": plural, "key": term}).Sort(weightedPagesSort...).Batch(1000).Iter()
 
 	pageIds := make(PageIds, 0)
 
@@ -734,6 +855,8 @@ func (ps *PageStore) loadPageIds(page *Page) {
 	}
 	page.SubSectionsIdsCount = len(page.SubSectionsIds)
 
+	ps.attachDataPages(page)
+
 	elapsed := time.Since(start_p)
 	fmt.Println("Redis get ids ", page.ID, " ", page.Kind, " ", elapsed, " ", MyCaller())
 
@@ -763,13 +886,15 @@ func (ps *PageStore) pageToPageModel(p *Page) PageModel {
 	//	shortCodeOrderedMap = orderedMapMongo{}
 	//}
 
-	return PageModel{
+	pageModel := PageModel{
 		Kind:              p.Kind,
 		Resources:         p.Resources,
 		ResourcesMetadata: p.resourcesMetadata,
 		TranslationsIds:   p.translationsIds,
 		TranslationKey:    p.translationKey,
+		TranslationGroup:  p.TranslationKey(),
 		Params:            p.params,
+		SortWeight:        storeSortWeight(p.params["weight"]),
 		ContentV:          p.contentv,
 		Summary:           p.summary,
 		TableOfContents:   p.TableOfContents,
@@ -797,15 +922,20 @@ func (ps *PageStore) pageToPageModel(p *Page) PageModel {
 		PlainWords:        p.plainWords,
 		RenderingConfig:   p.renderingConfig,
 		PageMenus:         p.pageMenus,
-		Position:          p.Position,
+		Position:          p.positionRefs,
 		GitInfo:           p.GitInfo,
 		Sections:          p.sections,
 		ParentId:          p.ParentId,
+		FacetCombination:  p.facetCombination,
+		ThinAction:        p.thinAction,
+		CanonicalURL:      p.canonicalURL,
+		RecordKey:         p.recordKey,
+		VariantMaster:     p.variantMaster,
 		OrigOnCopyId:      p.origOnCopyId,
 		Title:             p.title,
 		Description:       p.Description,
 		Keywords:          p.Keywords,
-		Data:              p.Data,
+		Data:              pageDataForStore(p.Data),
 		PageDates:         p.PageDates,
 		Sitemap:           p.Sitemap,
 		UrlPath:           p.URLPath,
@@ -823,9 +953,16 @@ func (ps *PageStore) pageToPageModel(p *Page) PageModel {
 		ID:                p.ID,
 		PagePath:          p.pagePath,
 	}
+
+	if ps.compressBlobs {
+		ps.compressPageModel(&pageModel)
+	}
+
+	return pageModel
 }
 
 func (ps *PageStore) pageModelToPage(p *PageModel) Page {
+	ps.decompressPageModel(p)
 
 	page := Page{
 		Kind:              p.Kind,
@@ -860,10 +997,16 @@ func (ps *PageStore) pageModelToPage(p *PageModel) Page {
 		plainWords:        p.PlainWords,
 		renderingConfig:   p.RenderingConfig,
 		pageMenus:         p.PageMenus,
-		Position:          p.Position,
+		positionRefs:      p.Position,
+		Position:          p.Position.position(ps),
 		GitInfo:           p.GitInfo,
 		sections:          p.Sections,
 		ParentId:          p.ParentId,
+		facetCombination:  p.FacetCombination,
+		thinAction:        p.ThinAction,
+		canonicalURL:      p.CanonicalURL,
+		recordKey:         p.RecordKey,
+		variantMaster:     p.VariantMaster,
 		origOnCopyId:      p.OrigOnCopyId,
 		title:             p.Title,
 		Description:       p.Description,
@@ -1063,54 +1206,23 @@ func (ps *PageStore) addPageIds(p *Page) {
 }
 
 type WeightedPagePipe struct {
-	Count       int
-	ID          string `bson:"_id"`
-	SearchLabel string
-	SearchKeys  []string
+	Count int
+	ID    string `bson:"_id"`
 }
 
 type WeightedPagePipes []WeightedPagePipe
 
+// taxonomyTermsByCount reads the term counts materialized for this build,
+// see materializeTaxonomyTerms.
 func (ps *PageStore) taxonomyTermsByCount(plural string) []WeightedPagePipe {
-
-	//cache_items, found := ps.cache.Get("taxonomyTermsByCount" + plural)
-	//
-	//if found {
-	//	return cache_items.([]WeightedPagePipe)
-	//}
-
-	start := time.Now()
-	pipe := []bson.M{bson.M{"$match": bson.M{"plural": plural}}, bson.M{"$group": bson.M{"_id": "$key", "count": bson.M{"$sum": 1}}}, bson.M{"$sort": bson.M{"count": -1}}}
-
-	items := ps.MongoSession.DB("hugo").C("weighted_pages").Pipe(pipe).Iter()
+	items := ps.MongoSession.DB("hugo").C("taxonomy_terms").Find(bson.M{"plural": plural}).Sort("-count", "key").Select(bson.M{"key": 1, "count": 1}).Batch(1000).Iter()
 	weightedPagePipes := make([]WeightedPagePipe, 0)
 
-	item := WeightedPagePipe{}
+	item := TaxonomyTerm{}
 	for items.Next(&item) {
-		weightedPagePipes = append(weightedPagePipes, item)
+		weightedPagePipes = append(weightedPagePipes, WeightedPagePipe{ID: item.Key, Count: item.Count})
 	}
 
-	elapsed := time.Since(start)
-	fmt.Println(" term count Took ", elapsed, " ", MyCaller())
-
-	return weightedPagePipes
-}
-
-func (ps *PageStore) taxonomyTermsWithBsonMByCount(bsonM bson.M) []WeightedPagePipe {
-	//start := time.Now()
-	pipe := []bson.M{bson.M{"$match": bsonM}, bson.M{"$group": bson.M{"_id": "$key", "searchlabel": bson.M{"$first": "$searchlabel"}, "searchkeys": bson.M{"$first": "$searchkeys"}, "count": bson.M{"$sum": 1}}}, bson.M{"$sort": bson.M{"count": -1}}}
-
-	items := ps.MongoSession.DB("hugo").C("weighted_pages").Pipe(pipe).Iter()
-	weightedPagePipes := make([]WeightedPagePipe, 0)
-
-	item := WeightedPagePipe{}
-	for items.Next(&item) {
-		weightedPagePipes = append(weightedPagePipes, item)
-	}
-
-	//elapsed := time.Since(start)
-	//fmt.Println(" term count with bson Took ", elapsed, " ", MyCaller())
-
 	return weightedPagePipes
 }
 
@@ -1135,48 +1247,15 @@ func (ps *PageStore) getHomePage() *Page {
 }
 
 func (ps *PageStore) getPageByHumanId(humanId string) *Page {
-
-	pageModel := PageModel{}
-	err := ps.MongoSession.DB("hugo").C("pages").Find(bson.M{"params.page_human_id": humanId}).One(&pageModel)
-
-	if err != nil && err.Error() == "not found" {
-		return nil
-	}
-
-	if err != nil {
-		fmt.Println(err.Error())
-		panic(err)
-	}
-
-	page := ps.pageModelToPage(&pageModel)
-	ps.loadPageIds(&page)
-
-	return &page
+	return ps.getPageBy(defaultLookupKey, humanId)
 }
 
 func (ps *PageStore) getPagesByHumanIds(humanIds []string) Pages {
-
-	var results []PageModel
-	err := ps.MongoSession.DB("hugo").C("pages").Find(bson.M{"params.page_human_id": bson.M{"$in": humanIds}}).All(&results)
-
-	if err != nil && err.Error() == "not found" {
-		return nil
-	}
-
-	if err != nil {
-		fmt.Println(err.Error())
-		panic(err)
+	values := make([]interface{}, len(humanIds))
+	for i, humanId := range humanIds {
+		values[i] = humanId
 	}
-
-	pages := make(Pages, 0)
-
-	for _, pm := range results {
-		pageP := ps.pageModelToPage(&pm)
-		ps.loadPageIds(&pageP)
-		pages = append(pages, &pageP)
-	}
-
-	return pages
+	return ps.getPagesBy(defaultLookupKey, values)
 }
 
 func (ps *PageStore) setPagePermalinkByPageHumanId(humanId string, permalink string) {
@@ -1197,7 +1276,7 @@ type LitePage struct {
 	MasterVariation  bool          `json:"m,omitempty"`
 }
 
-func (ps *PageStore) setLitePageById(prefix string, id string, page *Page) {
+func (ps *PageStore) setLitePage(key string, page *Page) {
 	litePage := LitePage{
 		Permalink:   page.Permalink(),
 		Title:       page.Title(),
@@ -1206,33 +1285,30 @@ func (ps *PageStore) setLitePageById(prefix string, id string, page *Page) {
 		Truncated:   page.Truncated(),
 	}
 
+	// Ingested and enriched values are not typed as in front matter, e.g.
+	// every value of a CSV source is a string.
 	if val, ok := page.params["image"]; ok && val != nil {
-		litePage.Image = val.(string);
+		litePage.Image = cast.ToString(val)
 	}
 
 	if val, ok := page.params["total_review_count"]; ok && val != nil {
-		litePage.TotalReviewCount = val.(float64);
+		// Ingested and joined numbers can be integers.
+		litePage.TotalReviewCount = cast.ToFloat64(val)
 	}
 
 	if val, ok := page.params["stars_class"]; ok && val != nil {
-		litePage.StarsClass = val.(string);
+		litePage.StarsClass = cast.ToString(val)
 	}
 
 	if val, ok := page.params["price"]; ok && val != nil {
-		litePage.Price = val.(float64);
+		litePage.Price = cast.ToFloat64(val)
 	}
 	if val, ok := page.params["master_variation"]; ok && val != nil {
-		litePage.MasterVariation = val.(bool);
+		litePage.MasterVariation = cast.ToBool(val)
 	}
 
 	if val, ok := page.params["tags"]; ok && val != nil {
-		stringList := make([]string, 0)
-
-		for _, x := range val.([]interface{}) {
-			stringList = append(stringList, x.(string))
-		}
-
-		litePage.Tags = stringList
+		litePage.Tags = cast.ToStringSlice(val)
 	}
 
 	listPageJson, err := json.Marshal(litePage)
@@ -1242,26 +1318,11 @@ func (ps *PageStore) setLitePageById(prefix string, id string, page *Page) {
 		panic(err)
 	}
 
-	ps.RDBSet(prefix+"_"+id, string(listPageJson))
+	ps.RDBSet(key, string(listPageJson))
 }
 
 func (ps *PageStore) getLitePageByHumanId(humanId string) *LitePage {
-	start_p := time.Now()
-	//litePageBytes, _ := ps.Redis.Get("lite_" + humanId).Result()
-	litePageBytes := ps.RDBGet("lite_" + humanId)
-
-	if len(litePageBytes) == 0 {
-		return nil
-	}
-
-	var litePage LitePage
-	json.Unmarshal([]byte(litePageBytes), &litePage)
-
-	elapsed := time.Since(start_p)
-	fmt.Println("getLitePageByHumanId ", humanId, " ", elapsed, " ", MyCaller())
-
-	return &litePage
-
+	return ps.getLitePageBy(defaultLookupKey, humanId)
 }
 
 func (ps *PageStore) getLitePageById(humanId string) *LitePage {
@@ -1364,6 +1425,31 @@ func (ps *PageStore) RDBSet(key string, value string) {
 	ps.RocksDb.Put(wo, []byte( key), []byte(value))
 }
 
+// RDBMSet writes the given keys and values in one batch.
+func (ps *PageStore) RDBMSet(values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+
+	for key, value := range values {
+		wb.Put([]byte(key), []byte(value))
+	}
+
+	wo := gorocksdb.NewDefaultWriteOptions()
+	if err := ps.RocksDb.Write(wo, wb); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+}
+
+func (ps *PageStore) RDBDelete(key string) {
+	wo := gorocksdb.NewDefaultWriteOptions()
+	ps.RocksDb.Delete(wo, []byte(key))
+}
+
 func (ps *PageStore) startDebug() {
 	mgo.SetDebug(true)
 }
@@ -0,0 +1,233 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"strings"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/tecbot/gorocksdb"
+)
+
+var storeCompressionTypes = map[string]gorocksdb.CompressionType{
+	"none":   gorocksdb.NoCompression,
+	"snappy": gorocksdb.SnappyCompression,
+	"zlib":   gorocksdb.ZLibCompression,
+	"lz4":    gorocksdb.LZ4Compression,
+	"lz4hc":  gorocksdb.LZ4HCCompression,
+	"zstd":   gorocksdb.ZSTDCompression,
+}
+
+// rocksDbCompression returns the RocksDB block compression for the
+// storeCompression setting, e.g. "snappy" or "zstd". The setting must not
+// be empty; unset, RocksDB's default (snappy) is left in place.
+func rocksDbCompression(name string) (gorocksdb.CompressionType, error) {
+	compression, found := storeCompressionTypes[strings.ToLower(name)]
+	if !found {
+		return gorocksdb.NoCompression, fmt.Errorf("unknown storeCompression %q", name)
+	}
+
+	return compression, nil
+}
+
+// pageBlob holds the bulky content fields of a PageModel. When
+// storeCompressBlobs is set these are stored gzipped in PageModel.Blob
+// instead of as separate document fields.
+type pageBlob struct {
+	ContentV        template.HTML
+	Summary         template.HTML
+	TableOfContents template.HTML
+	Frontmatter     []byte
+	RawContent      []byte
+	WorkContent     []byte
+	Plain           string
+	PlainWords      []string
+}
+
+func (ps *PageStore) compressPageModel(pm *PageModel) {
+	blob := pageBlob{
+		ContentV:        pm.ContentV,
+		Summary:         pm.Summary,
+		TableOfContents: pm.TableOfContents,
+		Frontmatter:     pm.Frontmatter,
+		RawContent:      pm.RawContent,
+		WorkContent:     pm.WorkContent,
+		Plain:           pm.Plain,
+		PlainWords:      pm.PlainWords,
+	}
+
+	raw, err := bson.Marshal(blob)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	gz.Write(raw)
+	gz.Close()
+
+	pm.Blob = b.Bytes()
+	pm.BlobRawSize = len(raw)
+	pm.BlobSize = len(pm.Blob)
+
+	pm.ContentV = ""
+	pm.Summary = ""
+	pm.TableOfContents = ""
+	pm.Frontmatter = nil
+	pm.RawContent = nil
+	pm.WorkContent = nil
+	pm.Plain = ""
+	pm.PlainWords = nil
+}
+
+func (ps *PageStore) decompressPageModel(pm *PageModel) {
+	if len(pm.Blob) == 0 {
+		return
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(pm.Blob))
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	raw, err := ioutil.ReadAll(gz)
+	gz.Close()
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	blob := pageBlob{}
+
+	if err := bson.Unmarshal(raw, &blob); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	pm.ContentV = blob.ContentV
+	pm.Summary = blob.Summary
+	pm.TableOfContents = blob.TableOfContents
+	pm.Frontmatter = blob.Frontmatter
+	pm.RawContent = blob.RawContent
+	pm.WorkContent = blob.WorkContent
+	pm.Plain = blob.Plain
+	pm.PlainWords = blob.PlainWords
+}
+
+// StoreCollectionStats is the disk usage of one Mongo collection.
+type StoreCollectionStats struct {
+	Name        string
+	Count       int64
+	Size        int64
+	StorageSize int64
+
+	// Uncompressed and stored sizes of the page content blobs,
+	// only set when storeCompressBlobs was used.
+	BlobRawSize int64
+	BlobSize    int64
+}
+
+// StoreRocksDbStats is the disk usage of the RocksDB id and LitePage store.
+type StoreRocksDbStats struct {
+	Compression string
+	Keys        int64
+	RawSize     int64
+	SstSize     int64
+}
+
+// StoreStats is reported by hugo store stats.
+type StoreStats struct {
+	Collections []StoreCollectionStats
+	RocksDb     StoreRocksDbStats
+}
+
+var storeStatsCollections = []string{"pages", "raw_pages", "headless_pages", "weighted_pages", "taxonomy_terms"}
+
+// Stats collects the disk usage of the Mongo collections and the RocksDB
+// store together with the savings from compression.
+func (ps *PageStore) Stats() StoreStats {
+	stats := StoreStats{}
+
+	for _, name := range storeStatsCollections {
+		collStats := struct {
+			Count       int64 `bson:"count"`
+			Size        int64 `bson:"size"`
+			StorageSize int64 `bson:"storageSize"`
+		}{}
+
+		if err := ps.MongoSession.DB("hugo").Run(bson.D{{"collStats", name}}, &collStats); err != nil {
+			continue
+		}
+
+		cs := StoreCollectionStats{
+			Name:        name,
+			Count:       collStats.Count,
+			Size:        collStats.Size,
+			StorageSize: collStats.StorageSize,
+		}
+
+		pipe := []bson.M{{"$group": bson.M{"_id": nil, "raw": bson.M{"$sum": "$blobrawsize"}, "stored": bson.M{"$sum": "$blobsize"}}}}
+		blobSizes := struct {
+			Raw    int64 `bson:"raw"`
+			Stored int64 `bson:"stored"`
+		}{}
+
+		if err := ps.MongoSession.DB("hugo").C(name).Pipe(pipe).One(&blobSizes); err == nil {
+			cs.BlobRawSize = blobSizes.Raw
+			cs.BlobSize = blobSizes.Stored
+		}
+
+		stats.Collections = append(stats.Collections, cs)
+	}
+
+	compression := ps.Cfg.GetString("storeCompression")
+	if compression == "" {
+		compression = "default"
+	}
+
+	stats.RocksDb.Compression = compression
+	stats.RocksDb.SstSize = parseRocksDbIntProperty(ps.RocksDb.GetProperty("rocksdb.total-sst-files-size"))
+
+	ro := gorocksdb.NewDefaultReadOptions()
+	ro.SetFillCache(false)
+	it := ps.RocksDb.NewIterator(ro)
+	defer it.Close()
+
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		key := it.Key()
+		value := it.Value()
+		stats.RocksDb.Keys++
+		stats.RocksDb.RawSize += int64(key.Size() + value.Size())
+		key.Free()
+		value.Free()
+	}
+
+	return stats
+}
+
+func parseRocksDbIntProperty(value string) int64 {
+	var n int64
+	fmt.Sscan(value, &n)
+	return n
+}
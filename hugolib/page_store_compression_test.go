@@ -0,0 +1,68 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tecbot/gorocksdb"
+)
+
+func TestRocksDbCompression(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	for name, expected := range map[string]gorocksdb.CompressionType{
+		"none":   gorocksdb.NoCompression,
+		"snappy": gorocksdb.SnappyCompression,
+		"ZSTD":   gorocksdb.ZSTDCompression,
+	} {
+		compression, err := rocksDbCompression(name)
+		assert.NoError(err)
+		assert.Equal(expected, compression)
+	}
+
+	_, err := rocksDbCompression("brotli")
+	assert.Error(err)
+}
+
+func TestCompressPageModel(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	ps := &PageStore{}
+	content := strings.Repeat("<p>Lenovo ThinkPad</p>", 200)
+
+	pm := PageModel{
+		ID:         "page_laptops_thinkpad",
+		ContentV:   "<p>rendered</p>",
+		RawContent: []byte(content),
+		Plain:      "plain",
+		PlainWords: []string{"plain"},
+	}
+
+	ps.compressPageModel(&pm)
+
+	assert.Empty(pm.RawContent)
+	assert.Empty(pm.ContentV)
+	assert.True(pm.BlobSize < pm.BlobRawSize)
+
+	ps.decompressPageModel(&pm)
+
+	assert.Equal(content, string(pm.RawContent))
+	assert.Equal("<p>rendered</p>", string(pm.ContentV))
+	assert.Equal([]string{"plain"}, pm.PlainWords)
+}
@@ -0,0 +1,160 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+	"runtime"
+
+	// Use this until errgroup gets ported to context
+	// See https://github.com/golang/go/issues/19781
+	"golang.org/x/net/context"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const defaultIngestBatchSize = 500
+
+// pageIngester writes pages into a store collection for any number of
+// producers. Pages are passed on a bounded channel to a pool of writers,
+// each doing unordered bulk inserts on its own Mongo session.
+type pageIngester struct {
+	ps         *PageStore
+	collection string
+	batchSize  int
+
+	// Replace the pages with the same ids instead of inserting them.
+	upsert bool
+
+	pages chan *Page
+
+	ctx context.Context
+	g   *errgroup.Group
+}
+
+func (ps *PageStore) newPageIngester(ctx context.Context, collection string) *pageIngester {
+	return ps.startPageIngester(ctx, collection, false)
+}
+
+// newPageUpserter is newPageIngester for pages that may be in the
+// collection already, e.g. from an earlier hugo ingest.
+func (ps *PageStore) newPageUpserter(ctx context.Context, collection string) *pageIngester {
+	return ps.startPageIngester(ctx, collection, true)
+}
+
+func (ps *PageStore) startPageIngester(ctx context.Context, collection string, upsert bool) *pageIngester {
+	numWriters := runtime.NumCPU()
+	if n := ps.Cfg.GetInt("ingestWriters"); n > 0 {
+		numWriters = n
+	}
+
+	batchSize := defaultIngestBatchSize
+	if n := ps.Cfg.GetInt("ingestBatchSize"); n > 0 {
+		batchSize = n
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	in := &pageIngester{
+		ps:         ps,
+		collection: collection,
+		batchSize:  batchSize,
+		upsert:     upsert,
+		pages:      make(chan *Page, numWriters*batchSize),
+		ctx:        ctx,
+		g:          g,
+	}
+
+	for i := 0; i < numWriters; i++ {
+		g.Go(in.write)
+	}
+
+	return in
+}
+
+// Add queues a page for writing. It is safe for concurrent use and
+// returns an error if a writer has failed.
+func (in *pageIngester) Add(p *Page) error {
+	select {
+	case in.pages <- p:
+		return nil
+	case <-in.ctx.Done():
+		return in.ctx.Err()
+	}
+}
+
+// Close flushes the queued pages and waits for the writers. It returns the
+// first error from any writer.
+func (in *pageIngester) Close() error {
+	close(in.pages)
+	return in.g.Wait()
+}
+
+func (in *pageIngester) write() error {
+	session := in.ps.MongoSession.Copy()
+	defer session.Close()
+
+	c := session.DB("hugo").C(in.collection)
+	batch := make([]interface{}, 0, in.batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		bulk := c.Bulk()
+		bulk.Unordered()
+
+		if in.upsert {
+			for _, doc := range batch {
+				bulk.Upsert(bson.M{"_id": doc.(PageModel).ID}, doc)
+			}
+		} else {
+			bulk.Insert(batch...)
+		}
+
+		if _, err := bulk.Run(); err != nil {
+			return fmt.Errorf("Failed to write %d pages to %s: %s", len(batch), in.collection, err)
+		}
+
+		batch = batch[:0]
+		return nil
+	}
+
+	// Runs until Close, so that everything queued before it is written.
+	for p := range in.pages {
+		if in.ctx.Err() != nil {
+			// Another writer failed or the build was cancelled, Add
+			// returns the error. Keep draining so Close does not block.
+			continue
+		}
+
+		pageModel := in.ps.pageToPageModel(p)
+		in.ps.storePageIds(*p)
+		batch = append(batch, pageModel)
+
+		if len(batch) >= in.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := in.ctx.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
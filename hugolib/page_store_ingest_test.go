@@ -0,0 +1,52 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageIngesterWritesAllBatches(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	// More pages than the writers hold in full batches, with a partial
+	// batch left for Close.
+	const numPages = 11
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", `
+baseURL = "http://example.com/"
+ingestBatchSize = 3
+ingestWriters = 2
+`)
+
+	for i := 1; i <= numPages; i++ {
+		b.WithContent(fmt.Sprintf("p%d.md", i), fmt.Sprintf("---\ntitle: Page %d\n---\nContent %d", i, i))
+	}
+
+	b.CreateSites().Build(BuildCfg{})
+
+	count, err := b.H.Sites[0].PageStore.MongoSession.DB("hugo").C("pages").Find(bson.M{"kind": KindPage}).Count()
+	assert.NoError(err)
+	assert.Equal(numPages, count)
+
+	for i := 1; i <= numPages; i++ {
+		b.AssertFileContent(fmt.Sprintf("public/p%d/index.html", i), fmt.Sprintf("Content %d", i))
+	}
+}
@@ -0,0 +1,504 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/spf13/cast"
+)
+
+const storePagesBatchSize = 200
+
+// The default page sort, see DefaultPageSort.
+var defaultStorePagesSort = []string{"sortweight", "-pagedates.date", "title"}
+
+// storeSortWeight is the weight pages are sorted by in the store. As in
+// DefaultPageSort, pages without a weight go after the weighted ones.
+func storeSortWeight(weight interface{}) int {
+	if w := cast.ToInt(weight); w != 0 {
+		return w
+	}
+	return math.MaxInt32
+}
+
+// backfillSortWeight sets the sort weight of the pages written before it
+// was stored.
+func (ps *PageStore) backfillSortWeight() error {
+	c := ps.MongoSession.DB("hugo").C("pages")
+
+	items := c.Find(bson.M{"sortweight": bson.M{"$exists": false}}).Select(bson.M{"params.weight": 1}).Batch(1000).Iter()
+
+	bulk := c.Bulk()
+	queued := 0
+
+	doc := struct {
+		ID     string `bson:"_id"`
+		Params map[string]interface{}
+	}{}
+
+	for items.Next(&doc) {
+		bulk.Update(bson.M{"_id": doc.ID}, bson.M{"$set": bson.M{"sortweight": storeSortWeight(doc.Params["weight"])}})
+		queued++
+
+		if queued == 1000 {
+			if _, err := bulk.Run(); err != nil {
+				items.Close()
+				return err
+			}
+			bulk = c.Bulk()
+			queued = 0
+		}
+
+		doc.Params = nil
+	}
+
+	if err := items.Close(); err != nil {
+		return err
+	}
+
+	if queued > 0 {
+		_, err := bulk.Run()
+		return err
+	}
+
+	return nil
+}
+
+// StorePages is a page collection that lives in the page store. Only the
+// query is kept in memory, pages are read in batches when the collection is
+// ranged over or sliced, so .Site.Pages and friends can be used on sites
+// with millions of pages.
+//
+// StorePages implements collections.Lazy, which is what makes range,
+// len, first, last, after and where work on it in templates.
+type StorePages struct {
+	ps *PageStore
+
+	query bson.M
+
+	// When set, the collection is exactly these pages, in this order,
+	// unless it is re-sorted.
+	ids PageIds
+
+	sort  []string
+	limit int
+
+	// The Stats of the collection by field, shared by its copies.
+	stats *pageStatsCache
+}
+
+func (ps *PageStore) storePages(query bson.M, sort ...string) StorePages {
+	if len(sort) == 0 {
+		sort = defaultStorePagesSort
+	}
+	return StorePages{ps: ps, query: query, sort: sort, stats: newPageStatsCache()}
+}
+
+func (ps *PageStore) storePagesByIds(ids PageIds) StorePages {
+	if ids == nil {
+		ids = PageIds{}
+	}
+	return StorePages{ps: ps, query: bson.M{}, ids: ids, stats: newPageStatsCache()}
+}
+
+func (sp StorePages) String() string {
+	return fmt.Sprintf("StorePages(%v %v)", sp.query, sp.sort)
+}
+
+func (sp StorePages) find() *mgo.Query {
+	return sp.ps.MongoSession.DB("hugo").C("pages").Find(sp.filter()).Sort(sp.sort...)
+}
+
+// filter returns the query the collection is read with.
+func (sp StorePages) filter() bson.M {
+	query := bson.M{}
+	for k, v := range sp.query {
+		query[k] = v
+	}
+
+	if sp.ids != nil {
+		query["_id"] = bson.M{"$in": sp.ids}
+	}
+
+	return query
+}
+
+// byIds is whether the collection can be read straight from its ids.
+func (sp StorePages) byIds() bool {
+	return sp.ids != nil && len(sp.sort) == 0
+}
+
+// Len returns the number of pages in the collection.
+func (sp StorePages) Len() int {
+	var n int
+
+	if sp.byIds() {
+		n = len(sp.ids)
+	} else {
+		var err error
+		n, err = sp.find().Count()
+
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}
+
+	if sp.limit > 0 && n > sp.limit {
+		n = sp.limit
+	}
+
+	return n
+}
+
+// Slice returns the pages from index from up to, but not including, to.
+func (sp StorePages) Slice(from, to int) interface{} {
+	return sp.slice(from, to)
+}
+
+func (sp StorePages) slice(from, to int) Pages {
+	if sp.limit > 0 && to > sp.limit {
+		to = sp.limit
+	}
+
+	if from < 0 {
+		from = 0
+	}
+
+	if to <= from {
+		return Pages{}
+	}
+
+	if sp.byIds() {
+		if to > len(sp.ids) {
+			to = len(sp.ids)
+		}
+		if to <= from {
+			return Pages{}
+		}
+		return sp.ps.getPagesByIdInOrder(sp.ids[from:to])
+	}
+
+	var results []PageModel
+
+	if err := sp.find().Skip(from).Limit(to - from).All(&results); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	pages := make(Pages, len(results))
+
+	for i := range results {
+		page := sp.ps.pageModelToPage(&results[i])
+		sp.ps.loadPageIds(&page)
+		pages[i] = &page
+	}
+
+	return pages
+}
+
+// Each calls f for every page in the collection, in order, until f returns
+// false. Only one batch of pages is held in memory at a time.
+func (sp StorePages) Each(f func(p *Page) bool) {
+	if sp.byIds() {
+		for from := 0; from < sp.Len(); from += storePagesBatchSize {
+			for _, p := range sp.slice(from, from+storePagesBatchSize) {
+				if !f(p) {
+					return
+				}
+			}
+		}
+		return
+	}
+
+	q := sp.find().Batch(storePagesBatchSize)
+	if sp.limit > 0 {
+		q = q.Limit(sp.limit)
+	}
+
+	items := q.Iter()
+	defer items.Close()
+
+	item := PageModel{}
+	for items.Next(&item) {
+		page := sp.ps.pageModelToPage(&item)
+		sp.ps.loadPageIds(&page)
+
+		if !f(&page) {
+			return
+		}
+
+		item = PageModel{}
+	}
+}
+
+// Iterate is Each for collections.Lazy.
+func (sp StorePages) Iterate(yield func(elem interface{}) bool) {
+	sp.Each(func(p *Page) bool {
+		return yield(p)
+	})
+}
+
+// Pages reads all the pages of the collection into memory.
+func (sp StorePages) Pages() Pages {
+	return sp.slice(0, sp.Len())
+}
+
+// PageIds returns the ids of the pages in the collection, in order.
+func (sp StorePages) PageIds() PageIds {
+	if sp.byIds() {
+		ids := sp.ids
+		if sp.limit > 0 && len(ids) > sp.limit {
+			ids = ids[:sp.limit]
+		}
+		return ids
+	}
+
+	q := sp.find().Select(bson.M{"_id": 1}).Batch(1000)
+	if sp.limit > 0 {
+		q = q.Limit(sp.limit)
+	}
+
+	pageIds := make(PageIds, 0)
+	items := q.Iter()
+
+	item := PageModel{}
+	for items.Next(&item) {
+		pageIds = append(pageIds, PageId(item.ID))
+	}
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return pageIds
+}
+
+// fixed pins a limited collection to its pages, so that sorting or
+// reversing it applies to those pages only.
+func (sp StorePages) fixed() StorePages {
+	if sp.limit == 0 {
+		return sp
+	}
+	return sp.ps.storePagesByIds(sp.PageIds())
+}
+
+func (sp StorePages) sortBy(sort ...string) StorePages {
+	sp = sp.fixed()
+	sp.sort = sort
+	return sp
+}
+
+// ByWeight sorts the collection by weight, then by date and title.
+func (sp StorePages) ByWeight() StorePages {
+	return sp.sortBy(defaultStorePagesSort...)
+}
+
+// ByTitle sorts the collection by title.
+func (sp StorePages) ByTitle() StorePages {
+	return sp.sortBy("title")
+}
+
+// ByLinkTitle sorts the collection by link title.
+func (sp StorePages) ByLinkTitle() StorePages {
+	return sp.sortBy("linktitle")
+}
+
+// ByDate sorts the collection by date, oldest first.
+func (sp StorePages) ByDate() StorePages {
+	return sp.sortBy("pagedates.date")
+}
+
+// ByPublishDate sorts the collection by publish date, oldest first.
+func (sp StorePages) ByPublishDate() StorePages {
+	return sp.sortBy("pagedates.publishdate")
+}
+
+// ByExpiryDate sorts the collection by expiry date.
+func (sp StorePages) ByExpiryDate() StorePages {
+	return sp.sortBy("pagedates.expirydate")
+}
+
+// ByLastmod sorts the collection by last modified date, oldest first.
+func (sp StorePages) ByLastmod() StorePages {
+	return sp.sortBy("pagedates.lastmod")
+}
+
+// ByParam sorts the collection by the given page parameter.
+func (sp StorePages) ByParam(paramsKey interface{}) StorePages {
+	return sp.sortBy("params." + strings.ToLower(fmt.Sprint(paramsKey)))
+}
+
+// Reverse reverses the order of the collection.
+func (sp StorePages) Reverse() StorePages {
+	sp = sp.fixed()
+
+	if sp.byIds() {
+		ids := make(PageIds, len(sp.ids))
+		for i, id := range sp.ids {
+			ids[len(ids)-1-i] = id
+		}
+		sp.ids = ids
+		return sp
+	}
+
+	sort := make([]string, len(sp.sort))
+	for i, field := range sp.sort {
+		if strings.HasPrefix(field, "-") {
+			sort[i] = strings.TrimPrefix(field, "-")
+		} else {
+			sort[i] = "-" + field
+		}
+	}
+
+	return sp.sortBy(sort...)
+}
+
+// Limit returns the first n pages of the collection.
+func (sp StorePages) Limit(n int) StorePages {
+	if sp.limit == 0 || n < sp.limit {
+		sp.limit = n
+		sp.stats = newPageStatsCache()
+	}
+	return sp
+}
+
+func (sp StorePages) equal(other StorePages) bool {
+	return sp.limit == other.limit &&
+		reflect.DeepEqual(sp.query, other.query) &&
+		reflect.DeepEqual(sp.ids, other.ids) &&
+		reflect.DeepEqual(sp.sort, other.sort)
+}
+
+// getPagesByIdInOrder is getPagesById that keeps the order of pageIds.
+func (ps *PageStore) getPagesByIdInOrder(pageIds PageIds) Pages {
+	byId := make(map[PageId]*Page, len(pageIds))
+	for _, p := range ps.getPagesById(pageIds) {
+		byId[PageId(p.ID)] = p
+	}
+
+	pages := make(Pages, 0, len(pageIds))
+	for _, id := range pageIds {
+		if p, found := byId[id]; found {
+			pages = append(pages, p)
+		}
+	}
+
+	return pages
+}
+
+// attachDataPages sets .Data.Pages on a node, or a page with a collection
+// query, loaded from the store, together with the other values in
+// storeAttachedData. None of them is written back, see pageDataForStore.
+func (ps *PageStore) attachDataPages(p *Page) {
+	if !p.listsPages() || ps.Site == nil {
+		return
+	}
+
+	if p.Data == nil {
+		p.Data = make(map[string]interface{})
+	}
+
+	switch p.Kind {
+	case KindPage:
+		if q, _, err := p.collectionQuery(); err == nil {
+			p.Data["Pages"] = ps.queryPages(q)
+		}
+	case KindHome:
+		p.Data["Pages"] = ps.Site.Info.RegularPages()
+	case KindSection:
+		// A query rather than the ids, so it can be paginated in the store.
+		p.Data["Pages"] = ps.storePages(bson.M{"lang": p.Lang(), "kind": KindPage, "parentid": p.ID})
+	case KindTaxonomy:
+		if c := p.facetCombination; c != nil {
+			p.Data["Combination"] = *c
+		}
+		p.Data["Pages"] = ps.storePagesByIds(p.PageIds)
+	case KindTaxonomyTerm:
+		if len(p.sections) > 0 {
+			terms := ps.Site.Info.Taxonomies[p.sections[0]]
+			p.Data["Terms"] = terms
+			p.Data["OrderedIndex"] = terms
+			p.Data["Index"] = terms
+		}
+		p.Data["Pages"] = ps.storePagesByIds(p.PageIds)
+	default:
+		p.Data["Pages"] = ps.storePagesByIds(p.PageIds)
+	}
+}
+
+// storeAttachedData are the keys of p.Data that hold values the store
+// cannot decode back into their types. They come back from the store as
+// plain maps, so they are set again when the page is loaded instead.
+var storeAttachedData = map[string]bool{
+	"Combination":  true,
+	"Terms":        true,
+	"OrderedIndex": true,
+	"Index":        true,
+}
+
+// pageDataForStore returns p.Data without the store-backed collections
+// and the keys in storeAttachedData, which are attached again when the
+// page is loaded.
+func pageDataForStore(data map[string]interface{}) map[string]interface{} {
+	if !hasStoreAttachedData(data) {
+		return data
+	}
+
+	stored := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if _, isStorePages := v.(StorePages); isStorePages || storeAttachedData[k] {
+			continue
+		}
+		stored[k] = v
+	}
+
+	return stored
+}
+
+func hasStoreAttachedData(data map[string]interface{}) bool {
+	for k, v := range data {
+		if _, isStorePages := v.(StorePages); isStorePages || storeAttachedData[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// Pages returns all pages in the current language.
+func (siteInfo *SiteInfo) Pages() StorePages {
+	return siteInfo.s.PageStore.storePages(bson.M{"lang": siteInfo.s.Language.Lang})
+}
+
+// RegularPages returns the regular pages in the current language.
+func (siteInfo *SiteInfo) RegularPages() StorePages {
+	return siteInfo.s.PageStore.storePages(bson.M{"kind": KindPage, "lang": siteInfo.s.Language.Lang})
+}
+
+// AllPages returns all pages in all languages.
+func (siteInfo *SiteInfo) AllPages() StorePages {
+	return siteInfo.s.PageStore.storePages(bson.M{})
+}
+
+// AllRegularPages returns the regular pages in all languages.
+func (siteInfo *SiteInfo) AllRegularPages() StorePages {
+	return siteInfo.s.PageStore.storePages(bson.M{"kind": KindPage})
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorePagesSort(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	ps := &PageStore{}
+
+	sp := ps.storePages(bson.M{"kind": KindPage})
+	assert.Equal(defaultStorePagesSort, sp.sort)
+	assert.Equal([]string{"-sortweight", "pagedates.date", "-title"}, sp.Reverse().sort)
+	assert.Equal([]string{"-pagedates.date"}, sp.ByDate().Reverse().sort)
+	assert.Equal([]string{"params.brand"}, sp.ByParam("Brand").sort)
+	assert.Equal(5, sp.Limit(10).Limit(5).Limit(7).limit)
+	assert.True(sp.ByTitle().equal(sp.ByTitle()))
+	assert.False(sp.ByTitle().equal(sp.ByDate()))
+
+	byIds := ps.storePagesByIds(PageIds{"a", "b", "c"})
+	assert.Equal(3, byIds.Len())
+	assert.Equal(2, byIds.Limit(2).Len())
+	assert.Equal(PageIds{"c", "b", "a"}, byIds.Reverse().PageIds())
+	assert.Equal(PageIds{"b", "a"}, byIds.Limit(2).Reverse().PageIds())
+	assert.Len(byIds.Slice(3, 10), 0)
+}
+
+func TestStoreSortWeight(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	// Unweighted pages last, as in DefaultPageSort.
+	assert.Equal(-1, storeSortWeight(-1))
+	assert.Equal(3, storeSortWeight(3))
+	assert.Equal(3, storeSortWeight("3"))
+	assert.True(storeSortWeight(nil) > storeSortWeight(1000000))
+	assert.Equal(storeSortWeight(nil), storeSortWeight(0))
+}
+
+func TestPageDataForStore(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	ps := &PageStore{}
+
+	data := map[string]interface{}{"Singular": "brand"}
+	assert.Equal(data, pageDataForStore(data))
+
+	data["Pages"] = ps.storePagesByIds(PageIds{"a"})
+	stored := pageDataForStore(data)
+	assert.Equal(map[string]interface{}{"Singular": "brand"}, stored)
+	assert.Contains(data, "Pages")
+
+	data = map[string]interface{}{"Singular": "brand", "Terms": StoreTaxonomy{}, "Index": StoreTaxonomy{}}
+	assert.Equal(map[string]interface{}{"Singular": "brand"}, pageDataForStore(data))
+	assert.Contains(data, "Terms")
+}
+
+func TestStorePagesBuild(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", facetTestConfig)
+	b.WithContent(facetTestContent...)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `{{ len .Data.Pages }}|{{ range $i, $p := .Data.Pages }}{{ if eq $i 2 }}{{ break }}{{ end }}{{ if eq $p.Title "P1" }}{{ continue }}{{ end }}{{ $p.Title }},{{ end }}|{{ range first 1 .Data.Pages }}{{ .Title }}{{ end }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/index.html", "3|P2,|P1")
+}
+
+func TestPagesIndexesBuild(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", facetTestConfig)
+	b.WithContent(facetTestContent...)
+	b.WithTemplates("_default/single.html", `{{ .Title }}`)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	// The build replaces the pages collection, and drops its indexes, more
+	// than once.
+	indexes, err := b.H.Sites[0].PageStore.MongoSession.DB("hugo").C("pages").Indexes()
+	assert.NoError(err)
+
+	var keys [][]string
+	for _, index := range indexes {
+		keys = append(keys, index.Key)
+	}
+
+	assert.Contains(keys, []string{"lang", "kind", "parentid", "sortweight", "-pagedates.date", "title"})
+	assert.Contains(keys, []string{"lang", "kind", "sortweight", "-pagedates.date", "title"})
+}
@@ -0,0 +1,349 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/gohugoio/hugo/helpers"
+)
+
+// storeSchemaVersion is the version of the PageModel and WeightedPageIds
+// documents written by this Hugo. Bump it, and register a storeMigration,
+// whenever a stored field is added, renamed, reshaped or dropped, so that a
+// store kept with noReset is never read as if nothing changed. A migration
+// for an added field says why a missing value is right, or backfills it.
+const storeSchemaVersion = 11
+
+const storeMetaID = "schema"
+
+// storeMeta records who wrote the page store. It lives in the store_meta
+// collection.
+type storeMeta struct {
+	ID            string `bson:"_id"`
+	SchemaVersion int
+	HugoVersion   string
+	CommitHash    string
+	UpdatedAt     time.Time
+}
+
+// A storeMigration upgrades a store from Version-1 to Version.
+type storeMigration struct {
+	Version     int
+	Description string
+	Migrate     func(ps *PageStore) error
+}
+
+// addedOptionalField is the migration of a field added with omitempty that
+// is only set on some pages. Pages written before the field lack it, which
+// is how the pages it does not apply to are stored too, so they read back
+// right without being touched.
+func addedOptionalField(field string) func(ps *PageStore) error {
+	return func(ps *PageStore) error {
+		return nil
+	}
+}
+
+var storeMigrations = []storeMigration{
+	{
+		Version:     1,
+		Description: "stores written before the schema was versioned",
+		Migrate: func(ps *PageStore) error {
+			// Pages without a blob were written before storeCompressBlobs
+			// and keep their content in the document fields, which is
+			// how decompressPageModel reads them.
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "pages sorted by sortweight, with unweighted pages last",
+		Migrate: func(ps *PageStore) error {
+			return ps.backfillSortWeight()
+		},
+	},
+	{
+		Version:     3,
+		Description: "facet combination pages",
+		Migrate:     addedOptionalField("facetcombination"),
+	},
+	{
+		Version:     4,
+		Description: "thin page actions",
+		Migrate:     addedOptionalField("thinaction"),
+	},
+	{
+		Version:     5,
+		Description: "canonical URLs",
+		Migrate:     addedOptionalField("canonicalurl"),
+	},
+	{
+		Version:     6,
+		Description: "variant masters",
+		Migrate: func(ps *PageStore) error {
+			// Every build marks the masters again, see markVariantMasters.
+			return nil
+		},
+	},
+	{
+		Version:     7,
+		Description: "translation groups",
+		Migrate: func(ps *PageStore) error {
+			// Every build stores the translation group of each page again when
+			// it assembles the pages, see materializeTranslations.
+			return nil
+		},
+	},
+	{
+		Version:     8,
+		Description: "positions stored as page references",
+		Migrate: func(ps *PageStore) error {
+			// Positions were stored as whole pages, which do not read back as
+			// page references. Every build stores them again, see
+			// storePositions.
+			return ps.unsetStoreField("position", "pages")
+		},
+	},
+	{
+		Version:     9,
+		Description: "weighted pages by language",
+		Migrate: func(ps *PageStore) error {
+			return ps.backfillWeightedPages(bson.M{"lang": 1}, func(pm PageModel) bson.M {
+				return bson.M{"lang": pm.Lang}
+			})
+		},
+	},
+	{
+		Version:     10,
+		Description: "weighted pages with the section, date and title of their page",
+		Migrate: func(ps *PageStore) error {
+			// Facet combinations are counted per section, and taxonomy
+			// pagers sort by date and title, on the weighted pages alone.
+			return ps.backfillWeightedPages(bson.M{"sections": 1, "pagedates.date": 1, "title": 1}, func(pm PageModel) bson.M {
+				section := ""
+				if len(pm.Sections) > 0 {
+					section = pm.Sections[0]
+				}
+				return bson.M{"section": section, "date": pm.PageDates.Date, "title": pm.Title}
+			})
+		},
+	},
+	{
+		Version:     11,
+		Description: "pages keyed by the id of their ingest record",
+		Migrate: func(ps *PageStore) error {
+			// The ids of the records are not stored anywhere else. Pages
+			// ingested before get theirs with the next full build; until
+			// then, an update that moves one of them adds a page.
+			return nil
+		},
+	},
+}
+
+// pendingStoreMigrations returns the migrations needed to bring a store at
+// version from up to storeSchemaVersion, in order.
+func pendingStoreMigrations(from int) ([]storeMigration, error) {
+	var pending []storeMigration
+
+	for _, m := range storeMigrations {
+		if m.Version > from && m.Version <= storeSchemaVersion {
+			pending = append(pending, m)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+
+	for i, m := range pending {
+		if m.Version != from+i+1 {
+			return nil, fmt.Errorf("No store migration registered for schema version %d", from+i+1)
+		}
+	}
+
+	if from+len(pending) != storeSchemaVersion {
+		return nil, fmt.Errorf("No store migration registered for schema version %d", from+len(pending)+1)
+	}
+
+	return pending, nil
+}
+
+// initStoreSchema makes sure the store matches the schema this Hugo writes.
+// A fresh store just gets stamped. A store kept with noReset is either
+// migrated (with storeMigrate) or rejected with an explanation.
+func (ps *PageStore) initStoreSchema(noReset bool) error {
+	if !noReset {
+		return ps.writeStoreMeta(storeSchemaVersion)
+	}
+
+	meta, found := ps.readStoreMeta()
+
+	if !found && ps.countPages() == 0 {
+		// Nothing in it yet.
+		return ps.writeStoreMeta(storeSchemaVersion)
+	}
+
+	if meta.SchemaVersion > storeSchemaVersion {
+		return fmt.Errorf("The page store has schema version %d, written by Hugo %s (%s), but this Hugo (%s) only understands version %d. Use a newer Hugo or build without --noReset.",
+			meta.SchemaVersion, meta.HugoVersion, meta.CommitHash, helpers.CurrentHugoVersion, storeSchemaVersion)
+	}
+
+	if meta.SchemaVersion < storeSchemaVersion {
+		migrations, err := pendingStoreMigrations(meta.SchemaVersion)
+
+		if err != nil {
+			return err
+		}
+
+		if !ps.Cfg.GetBool("storeMigrate") {
+			return fmt.Errorf("The page store has schema version %d, written by Hugo %s (%s), and needs %d migration(s) to reach version %d. Build with --storeMigrate to migrate it or without --noReset to rebuild it.",
+				meta.SchemaVersion, meta.HugoVersion, meta.CommitHash, len(migrations), storeSchemaVersion)
+		}
+
+		for _, m := range migrations {
+			fmt.Println("Migrating page store to schema version ", m.Version, ": ", m.Description)
+
+			if err := m.Migrate(ps); err != nil {
+				return fmt.Errorf("Page store migration to schema version %d failed: %s", m.Version, err)
+			}
+
+			if err := ps.writeStoreMeta(m.Version); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ps.writeStoreMeta(storeSchemaVersion)
+}
+
+func (ps *PageStore) readStoreMeta() (storeMeta, bool) {
+	meta := storeMeta{}
+
+	err := ps.MongoSession.DB("hugo").C("store_meta").FindId(storeMetaID).One(&meta)
+
+	if err == mgo.ErrNotFound {
+		return meta, false
+	}
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return meta, true
+}
+
+func (ps *PageStore) writeStoreMeta(version int) error {
+	meta := storeMeta{
+		ID:            storeMetaID,
+		SchemaVersion: version,
+		HugoVersion:   helpers.CurrentHugoVersion.String(),
+		CommitHash:    CommitHash,
+		UpdatedAt:     time.Now(),
+	}
+
+	_, err := ps.MongoSession.DB("hugo").C("store_meta").UpsertId(storeMetaID, meta)
+
+	return err
+}
+
+// renameStoreField renames a document field in the given collections,
+// for use in a storeMigration.
+func (ps *PageStore) renameStoreField(from, to string, collections ...string) error {
+	for _, c := range collections {
+		_, err := ps.MongoSession.DB("hugo").C(c).UpdateAll(bson.M{from: bson.M{"$exists": true}}, bson.M{"$rename": bson.M{from: to}})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backfillStoreField sets field to value on all documents in the given
+// collections that do not have it, for use in a storeMigration.
+func (ps *PageStore) backfillStoreField(field string, value interface{}, collections ...string) error {
+	for _, c := range collections {
+		_, err := ps.MongoSession.DB("hugo").C(c).UpdateAll(bson.M{field: bson.M{"$exists": false}}, bson.M{"$set": bson.M{field: value}})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unsetStoreField removes a document field from the given collections,
+// for use in a storeMigration when the field is recomputed by the next
+// build.
+// backfillWeightedPages sets the fields returned by set for the page on its
+// weighted pages, for fields that were added to WeightedPageIds after they
+// were written. Only the selected fields of the pages are read.
+func (ps *PageStore) backfillWeightedPages(selected bson.M, set func(pm PageModel) bson.M) error {
+	pages := ps.MongoSession.DB("hugo").C("pages")
+	weighted := ps.MongoSession.DB("hugo").C("weighted_pages")
+
+	items := pages.Find(bson.M{}).Select(selected).Batch(1000).Iter()
+
+	bulk := weighted.Bulk()
+	bulk.Unordered()
+	queued := 0
+
+	pm := PageModel{}
+	for items.Next(&pm) {
+		bulk.UpdateAll(bson.M{"pageid": pm.ID}, bson.M{"$set": set(pm)})
+		queued++
+
+		if queued == 1000 {
+			if _, err := bulk.Run(); err != nil {
+				items.Close()
+				return err
+			}
+			bulk = weighted.Bulk()
+			bulk.Unordered()
+			queued = 0
+		}
+
+		pm = PageModel{}
+	}
+
+	if err := items.Close(); err != nil {
+		return err
+	}
+
+	if queued > 0 {
+		_, err := bulk.Run()
+		return err
+	}
+
+	return nil
+}
+
+func (ps *PageStore) unsetStoreField(field string, collections ...string) error {
+	for _, c := range collections {
+		_, err := ps.MongoSession.DB("hugo").C(c).UpdateAll(bson.M{field: bson.M{"$exists": true}}, bson.M{"$unset": bson.M{field: ""}})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingStoreMigrations(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	pending, err := pendingStoreMigrations(0)
+	assert.NoError(err)
+	assert.Len(pending, storeSchemaVersion)
+
+	for i, m := range pending {
+		assert.Equal(i+1, m.Version)
+		assert.NotEmpty(m.Description)
+	}
+
+	pending, err = pendingStoreMigrations(storeSchemaVersion)
+	assert.NoError(err)
+	assert.Empty(pending)
+}
+
+func TestWeightedPagesMigrations(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", facetTestConfig)
+	b.WithContent(facetTestContent...)
+	b.WithTemplates("_default/single.html", `{{ .Title }}`)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	ps := b.H.Sites[0].PageStore
+	weighted := ps.MongoSession.DB("hugo").C("weighted_pages")
+
+	// As written before the fields were added.
+	_, err := weighted.UpdateAll(bson.M{}, bson.M{"$unset": bson.M{"lang": "", "section": "", "date": "", "title": ""}})
+	assert.NoError(err)
+
+	pending, err := pendingStoreMigrations(8)
+	assert.NoError(err)
+
+	for _, m := range pending {
+		assert.NoError(m.Migrate(ps))
+	}
+
+	var wp WeightedPageIds
+	assert.NoError(weighted.Find(bson.M{"plural": "brands", "key": "zeta"}).One(&wp))
+	assert.Equal("en", wp.Lang)
+	assert.Equal("products", wp.Section)
+	assert.Equal("P3", wp.Title)
+}
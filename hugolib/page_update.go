@@ -0,0 +1,589 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// UpdateResult is what an update of pushed records did.
+type UpdateResult struct {
+	// The pages written to the store.
+	Pages int `json:"pages"`
+
+	// The pages rendered: the updated pages, their section parents and the
+	// taxonomy terms they are or were listed in.
+	Rendered int `json:"rendered"`
+}
+
+// DecodeUpdateRecords reads pushed records, given as a JSON array of
+// objects or as one object per line.
+func DecodeUpdateRecords(r io.Reader) ([]map[string]interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var records []map[string]interface{}
+
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode record %d: %s", len(records)+1, err)
+		}
+
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+
+		for _, item := range items {
+			record, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("record %d is not an object", len(records)+1)
+			}
+			records = append(records, record)
+		}
+	}
+}
+
+// LoadFromStore prepares the sites to render pages of a page store built
+// before, without reading the content, as hugo update does.
+func (h *HugoSites) LoadFromStore() error {
+	first := h.Sites[0]
+
+	if err := first.initialize(); err != nil {
+		return err
+	}
+
+	if err := first.readDataFromSourceFS(); err != nil {
+		return err
+	}
+
+	for _, s := range h.Sites[1:] {
+		s.initializeSiteInfo()
+	}
+
+	for _, s := range h.Sites {
+		s.createTaxonomiesEntries()
+		s.loadTaxonomies()
+		s.loadMenus()
+	}
+
+	h.initRenderFormats()
+
+	return nil
+}
+
+// loadTaxonomies reads the taxonomies assembled by the last build.
+func (s *Site) loadTaxonomies() {
+	s.taxonomiesPluralSingular = make(map[string]string)
+	s.taxonomiesOrigKey = make(map[string]string)
+	s.Info.Taxonomies = make(StoreTaxonomyList)
+
+	for singular, plural := range s.Language.GetStringMapString("taxonomies") {
+		s.taxonomiesPluralSingular[plural] = singular
+		s.Info.Taxonomies[plural] = s.PageStore.loadTaxonomy(plural)
+		s.dropSkippedTerms(plural)
+
+		for key := range s.Info.Taxonomies[plural] {
+			s.Taxonomies[plural].add(key)
+		}
+	}
+}
+
+// Update writes the pages of pushed records of the named ingest source to
+// the page store and renders them and the listings they are in. Records of
+// pages not in the store are added to their section, replacing the page of
+// a record whose section or slug changed if the source has an id, see
+// IngestSourceConfig.ID; new sections and taxonomy terms get their pages
+// with the next full build. The translations of an updated page are linked
+// to it in the store, but only rendered again with the next full build.
+func (h *HugoSites) Update(source string, records []map[string]interface{}) (UpdateResult, error) {
+	h.updateMu.Lock()
+	defer h.updateMu.Unlock()
+
+	var (
+		result UpdateResult
+		found  bool
+	)
+
+	for _, s := range h.Sites {
+		for _, c := range s.languageIngestSources() {
+			if c.Name != source {
+				continue
+			}
+			found = true
+
+			enricher, err := s.newPageEnricher()
+			if err != nil {
+				return result, err
+			}
+
+			var (
+				render   Pages
+				rendered = make(map[string]bool)
+			)
+
+			for i, record := range records {
+				p, err := s.newPageFromRecord(c, record)
+				if err != nil {
+					return result, fmt.Errorf("record %d of ingest source %q: %s", i+1, c.Name, err)
+				}
+
+				if !p.shouldBuild() || !p.checkCollectionQuery() {
+					continue
+				}
+
+				enricher.enrich(p)
+
+				moved, err := s.removeMovedRecordPages(p)
+				if err != nil {
+					return result, err
+				}
+
+				dependents, err := s.updatePage(p)
+				if err != nil {
+					return result, err
+				}
+				dependents = append(dependents, moved...)
+
+				result.Pages++
+
+				for _, d := range dependents {
+					if !rendered[d.ID] {
+						rendered[d.ID] = true
+						render = append(render, d)
+					}
+				}
+			}
+
+			if err := s.renderPageSet(render); err != nil {
+				return result, err
+			}
+
+			result.Rendered += len(render)
+		}
+	}
+
+	if !found {
+		return result, fmt.Errorf("no ingest source named %q", source)
+	}
+
+	return result, nil
+}
+
+// updatePage writes p to the store the way a build would, and returns the
+// pages to render for it: p, its section parents and the taxonomy terms it
+// is or was listed in.
+func (s *Site) updatePage(p *Page) (Pages, error) {
+	ps := s.PageStore
+	id := PageId(p.ID)
+
+	if p.headless {
+		return nil, fmt.Errorf("%q is headless, which needs a full build", p.pathOrTitle())
+	}
+
+	var (
+		oldTerms          []WeightedPageIds
+		oldTranslationKey string
+		parent            *Page
+	)
+
+	if ps.pageExists(id) {
+		existing := ps.getPageById(id)
+		p.ParentId = existing.ParentId
+		p.pagePath = existing.pagePath
+		// Positions are only computed by full builds.
+		p.positionRefs = existing.positionRefs
+		oldTerms = ps.weightedPagesOf(id)
+		oldTranslationKey = existing.TranslationKey()
+		ps.removePageIndex(existing)
+	} else {
+		parent = s.sectionPageOf(p)
+		p.ParentId = PageId(parent.ID)
+	}
+
+	p.setValuesForKind(s)
+
+	if len(p.outputFormats) == 0 {
+		p.outputFormats = s.outputFormats[p.Kind]
+	}
+
+	if err := p.initPaths(); err != nil {
+		return nil, err
+	}
+
+	if err := p.prepareLayouts(); err != nil {
+		return nil, err
+	}
+
+	if err := p.prepareData(s); err != nil {
+		return nil, err
+	}
+
+	ps.upsertPage(p)
+
+	if s.multilingualEnabled() {
+		p.translationsIds = ps.refreshTranslations(p.TranslationKey())
+		if oldTranslationKey != p.TranslationKey() {
+			ps.refreshTranslations(oldTranslationKey)
+		}
+	}
+
+	if parent != nil {
+		ps.storePageIds(Page{ID: parent.ID, PageIds: ps.insertPageId(parent, id)})
+	}
+
+	if group, ok := p.variantGroup(); ok {
+		ps.markVariantMasters(p.Lang(), group)
+		p.variantMaster = ps.isVariantMaster(id)
+	}
+
+	ps.storePageIds(*p)
+	ps.setLitePages(p, s.lookupKeys)
+	ps.setPageIndex(p)
+
+	ps.removeWeightedPages(id)
+	for plural := range s.Taxonomies {
+		s.assignTaxonomyTerms(plural, p)
+	}
+
+	terms := append(oldTerms, ps.weightedPagesOf(id)...)
+	s.refreshTaxonomyTerms(terms)
+
+	listings, err := s.listingsOf(p, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(Pages{p}, listings...), nil
+}
+
+// removeMovedRecordPages removes the stored pages of the record of p at
+// other paths than p, left behind when the section or slug of the record
+// changed, see IngestSourceConfig.ID. It returns the pages to render for
+// them: their section parents and the taxonomy terms they were listed in.
+func (s *Site) removeMovedRecordPages(p *Page) (Pages, error) {
+	ps := s.PageStore
+
+	if p.recordKey == "" {
+		return nil, nil
+	}
+
+	ids := ps.getPageIds(bson.M{"lang": p.Lang(), "recordkey": p.recordKey, "_id": bson.M{"$ne": p.ID}}, nil)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var dependents Pages
+
+	for _, old := range ps.getPagesById(ids) {
+		id := PageId(old.ID)
+		terms := ps.weightedPagesOf(id)
+
+		ps.removeWeightedPages(id)
+		ps.removeLitePages(old, s.lookupKeys)
+		ps.removePageIndex(old)
+
+		if err := ps.MongoSession.DB("hugo").C("pages").RemoveId(old.ID); err != nil && err != mgo.ErrNotFound {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+
+		if old.ParentId != "" && ps.pageExists(old.ParentId) {
+			ps.removePageId(ps.getPageById(old.ParentId), id)
+		}
+
+		if s.multilingualEnabled() {
+			ps.refreshTranslations(old.TranslationKey())
+		}
+
+		if group, ok := old.variantGroup(); ok {
+			ps.markVariantMasters(old.Lang(), group)
+		}
+
+		s.refreshTaxonomyTerms(terms)
+
+		listings, err := s.listingsOf(old, terms)
+		if err != nil {
+			return nil, err
+		}
+
+		dependents = append(dependents, listings...)
+	}
+
+	return dependents, nil
+}
+
+// listingsOf returns the section parents of p and the list pages of the
+// taxonomy terms, with their page ids stored again.
+func (s *Site) listingsOf(p *Page, terms []WeightedPageIds) (Pages, error) {
+	ps := s.PageStore
+
+	var dependents Pages
+
+	for parentId := p.ParentId; parentId != ""; {
+		parent := ps.getPageById(parentId)
+		dependents = append(dependents, parent)
+		if parent.ParentId == parentId {
+			break
+		}
+		parentId = parent.ParentId
+	}
+
+	seen := make(map[string]bool)
+
+	for _, t := range terms {
+		if seen[t.Plural+"_"+t.Key] {
+			continue
+		}
+		seen[t.Plural+"_"+t.Key] = true
+
+		for _, termPage := range ps.taxonomyPages(s.Language.Lang, t.Plural, t.Key) {
+			if err := termPage.prepareData(s); err != nil {
+				return nil, err
+			}
+			ps.storePageIds(*termPage)
+			dependents = append(dependents, termPage)
+		}
+	}
+
+	return dependents, nil
+}
+
+// removePageId stores the page ids of parent without id.
+func (ps *PageStore) removePageId(parent *Page, id PageId) {
+	ids := make(PageIds, 0, len(parent.PageIds))
+
+	for _, pid := range parent.PageIds {
+		if pid != id {
+			ids = append(ids, pid)
+		}
+	}
+
+	if len(ids) == 0 {
+		// storePageIds leaves empty ids as they were.
+		ps.RDBDelete(parent.ID + "_PageIds")
+		return
+	}
+
+	ps.storePageIds(Page{ID: parent.ID, PageIds: ids})
+}
+
+// insertPageId returns the ids of the pages of parent with the stored page
+// id added where it goes in the default sort: before the first of the pages
+// after it, or at the end.
+func (ps *PageStore) insertPageId(parent *Page, id PageId) PageIds {
+	ids := make(PageIds, 0, len(parent.PageIds)+1)
+
+	sorted := ps.getPageIds(bson.M{"lang": parent.Lang(), "kind": KindPage, "parentid": parent.ID}, defaultStorePagesSort)
+
+	var next PageId
+	for i, sid := range sorted {
+		if sid == id && i+1 < len(sorted) {
+			next = sorted[i+1]
+			break
+		}
+	}
+
+	for _, pid := range parent.PageIds {
+		if pid == next {
+			ids = append(ids, id)
+		}
+		ids = append(ids, pid)
+	}
+
+	if len(ids) == len(parent.PageIds) {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// sectionPageOf returns the section page a new page goes in, the home page
+// if its section has none yet.
+func (s *Site) sectionPageOf(p *Page) *Page {
+	if len(p.sections) > 0 {
+		ids := s.PageStore.getPageIds(bson.M{"lang": s.Language.Lang, "kind": KindSection, "sections": p.sections}, nil)
+		if len(ids) > 0 {
+			return s.PageStore.getPageById(ids[0])
+		}
+		s.Log.WARN.Printf("Section %q of %q is new, the page is listed on the home page until the next full build", p.Section(), p.pathOrTitle())
+	}
+
+	return s.PageStore.getHomePage()
+}
+
+// refreshTaxonomyTerms recounts the given terms after an update.
+func (s *Site) refreshTaxonomyTerms(terms []WeightedPageIds) {
+	seen := make(map[string]bool)
+
+	for _, t := range terms {
+		id := t.Plural + "_" + t.Key
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		taxonomy, found := s.Info.Taxonomies[t.Plural]
+		if !found {
+			continue
+		}
+
+		// New terms, and those skipped as thin, get their page, and are
+		// listed, with the next full build.
+		if _, listed := taxonomy[t.Key]; !listed {
+			continue
+		}
+
+		if term := s.PageStore.refreshTaxonomyTerm(t.Plural, t.Key); term != nil {
+			taxonomy[t.Key] = term
+		} else {
+			delete(taxonomy, t.Key)
+		}
+	}
+}
+
+func (ps *PageStore) upsertPage(p *Page) {
+	pageModel := ps.pageToPageModel(p)
+
+	if _, err := ps.MongoSession.DB("hugo").C("pages").UpsertId(pageModel.ID, pageModel); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+}
+
+// weightedPagesOf returns the taxonomy terms the page is listed in.
+func (ps *PageStore) weightedPagesOf(pageId PageId) []WeightedPageIds {
+	var items []WeightedPageIds
+
+	if err := ps.MongoSession.DB("hugo").C("weighted_pages").Find(bson.M{"pageid": pageId}).All(&items); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return items
+}
+
+func (ps *PageStore) removeWeightedPages(pageId PageId) {
+	if _, err := ps.MongoSession.DB("hugo").C("weighted_pages").RemoveAll(bson.M{"pageid": pageId}); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+}
+
+// refreshTaxonomyTerm recounts one term into taxonomy_terms, see
+// materializeTaxonomyTerms. It returns nil if no page is left in it.
+func (ps *PageStore) refreshTaxonomyTerm(plural, key string) *TaxonomyTerm {
+	pipe := []bson.M{
+		{"$match": bson.M{"plural": plural, "key": key}},
+		{"$group": bson.M{
+			"_id":    nil,
+			"count":  bson.M{"$sum": 1},
+			"weight": bson.M{"$min": "$weight"},
+		}},
+	}
+
+	var counted struct {
+		Count  int
+		Weight int
+	}
+
+	term := TaxonomyTerm{ID: plural + "_" + key, Plural: plural, Key: key, ps: ps}
+	terms := ps.MongoSession.DB("hugo").C("taxonomy_terms")
+
+	err := ps.MongoSession.DB("hugo").C("weighted_pages").Pipe(pipe).One(&counted)
+
+	if err == mgo.ErrNotFound {
+		if err := terms.RemoveId(term.ID); err != nil && err != mgo.ErrNotFound {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+		return nil
+	}
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	term.Count = counted.Count
+	term.Weight = counted.Weight
+
+	if _, err := terms.UpsertId(term.ID, term); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return &term
+}
+
+// taxonomyPages returns the list pages of a taxonomy term.
+func (ps *PageStore) taxonomyPages(lang, plural, key string) Pages {
+	ids := ps.getPageIds(bson.M{"lang": lang, "kind": KindTaxonomy, "sections": []string{plural, key}}, nil)
+	if len(ids) == 0 {
+		return nil
+	}
+	return ps.getPagesById(ids)
+}
+
+// renderPageSet renders the given pages as renderPages would.
+func (s *Site) renderPageSet(pages Pages) error {
+	if len(pages) == 0 {
+		return nil
+	}
+
+	results := make(chan error)
+	pagesChan := make(chan *Page)
+	errs := make(chan error)
+
+	go errorCollator(results, errs)
+
+	numWorkers := getGoMaxProcs()
+	if numWorkers > len(pages) {
+		numWorkers = len(pages)
+	}
+
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go pageRenderer(s, pagesChan, results, wg)
+	}
+
+	for _, p := range pages {
+		pagesChan <- p
+	}
+
+	close(pagesChan)
+
+	wg.Wait()
+
+	close(results)
+
+	if err := <-errs; err != nil {
+		return fmt.Errorf("Error(s) rendering pages: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeUpdateRecords(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	records, err := DecodeUpdateRecords(strings.NewReader(`[{"sku": "a", "price": 9.99}, {"sku": "b"}]`))
+	assert.NoError(err)
+	assert.Equal([]map[string]interface{}{
+		{"sku": "a", "price": json.Number("9.99")},
+		{"sku": "b"},
+	}, records)
+
+	records, err = DecodeUpdateRecords(strings.NewReader("{\"sku\": \"a\"}\n{\"sku\": \"b\"}\n"))
+	assert.NoError(err)
+	assert.Len(records, 2)
+	assert.Equal("b", records[1]["sku"])
+
+	records, err = DecodeUpdateRecords(strings.NewReader(""))
+	assert.NoError(err)
+	assert.Len(records, 0)
+
+	_, err = DecodeUpdateRecords(strings.NewReader(`["a"]`))
+	assert.Error(err)
+
+	_, err = DecodeUpdateRecords(strings.NewReader(`{"sku": `))
+	assert.Error(err)
+}
+
+func TestUpdateRendersChangedAndNewPages(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "hugo-update")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "products.jsonl")
+	assert.NoError(ioutil.WriteFile(source, []byte(
+		`{"sku": "p1", "title": "One", "weight": 1}`+"\n"+
+			`{"sku": "p3", "title": "Three", "weight": 3}`+"\n"), 0644))
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", fmt.Sprintf(`
+baseURL = "http://example.com/"
+
+[[ingest]]
+name = "products"
+source = %q
+section = "products"
+slug = "{{ .sku }}"
+`, source))
+	b.WithTemplates(
+		"_default/single.html", `Title: {{ .Title }}`,
+		"_default/list.html", `{{ .Title }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/p1/index.html", "Title: One")
+
+	result, err := b.H.Update("products", []map[string]interface{}{
+		{"sku": "p1", "title": "One changed", "weight": 1},
+		{"sku": "p2", "title": "Two", "weight": 2},
+	})
+	assert.NoError(err)
+	assert.Equal(2, result.Pages)
+
+	b.AssertFileContent("public/products/p1/index.html", "Title: One changed")
+	b.AssertFileContent("public/products/p2/index.html", "Title: Two")
+
+	// The new page goes right before the page after it in the default sort.
+	ps := b.H.Sites[0].PageStore
+	sections := ps.getPageIds(bson.M{"kind": KindSection, "sections": []string{"products"}}, nil)
+	assert.Len(sections, 1)
+
+	var titles []string
+	for _, id := range ps.getPageById(sections[0]).PageIds {
+		titles = append(titles, ps.getPageById(id).Title())
+	}
+	assert.Len(titles, 3)
+	assert.Contains(titles, "Two")
+
+	for i, title := range titles {
+		if title == "Two" {
+			assert.True(i+1 < len(titles), "%v", titles)
+			assert.Equal("Three", titles[i+1])
+		}
+	}
+}
+
+func TestUpdateReplacesPageOfMovedRecord(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "hugo-update")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "products.jsonl")
+	assert.NoError(ioutil.WriteFile(source, []byte(
+		`{"sku": "p1", "title": "One", "weight": 1}`+"\n"+
+			`{"sku": "p2", "title": "Two", "weight": 2}`+"\n"), 0644))
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", fmt.Sprintf(`
+baseURL = "http://example.com/"
+
+[[ingest]]
+name = "products"
+source = %q
+section = "products"
+slug = "{{ .title | urlize }}"
+id = "sku"
+`, source))
+	b.WithTemplates(
+		"_default/single.html", `Title: {{ .Title }}`,
+		"_default/list.html", `{{ .Title }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/one/index.html", "Title: One")
+
+	_, err = b.H.Update("products", []map[string]interface{}{
+		{"sku": "p1", "title": "Uno", "weight": 1},
+	})
+	assert.NoError(err)
+
+	b.AssertFileContent("public/products/uno/index.html", "Title: Uno")
+
+	ps := b.H.Sites[0].PageStore
+
+	ids := ps.getPageIds(bson.M{"recordkey": "products:p1"}, nil)
+	assert.Len(ids, 1)
+	assert.Equal("Uno", ps.getPageById(ids[0]).Title())
+
+	_, found := ps.getPermalink("/products/one/")
+	assert.False(found)
+
+	sections := ps.getPageIds(bson.M{"kind": KindSection, "sections": []string{"products"}}, nil)
+	assert.Len(sections, 1)
+	assert.Len(ps.getPageById(sections[0]).PageIds, 2)
+}
@@ -107,6 +107,10 @@ func (p *Pager) Pages() Pages {
 		return pages
 	}
 
+	if sp, ok := p.element().(*storePager); ok {
+		return sp.cursor.ps.getPagesByIdInOrder(sp.pageIds())
+	}
+
 	return paginatorEmptyPages
 }
 
@@ -122,6 +126,19 @@ func (p *Pager) PipePages() WeightedPagePipes {
 	return make(WeightedPagePipes, 0)
 }
 
+// GroupCounts returns the group counts on this page, see StorePages.CountBy.
+func (p *Pager) GroupCounts() PageGroupCounts {
+	if len(p.paginatedElements) == 0 {
+		return make(PageGroupCounts, 0)
+	}
+
+	if counts, ok := p.element().(PageGroupCounts); ok {
+		return counts
+	}
+
+	return make(PageGroupCounts, 0)
+}
+
 func (p *Pager) PageIds() PageIds {
 	if len(p.paginatedElements) == 0 {
 		return paginatorEmptyPageIds
@@ -131,6 +148,10 @@ func (p *Pager) PageIds() PageIds {
 		return pages
 	}
 
+	if sp, ok := p.element().(*storePager); ok {
+		return sp.pageIds()
+	}
+
 	return paginatorEmptyPageIds
 }
 
@@ -144,6 +165,10 @@ func (p *Pager) BulkPages(site *SiteInfo) Pages {
 
 	}
 
+	if sp, ok := p.element().(*storePager); ok {
+		return site.s.PageStore.getPagesById(sp.pageIds())
+	}
+
 	return paginatorEmptyBulkPages
 }
 
@@ -157,6 +182,10 @@ func (p *Pager) BulkLitePages(site *SiteInfo) []LitePage {
 
 	}
 
+	if sp, ok := p.element().(*storePager); ok {
+		return site.s.PageStore.getLitePagesById(sp.pageIds())
+	}
+
 	return paginatorEmptyBulkLitePages
 }
 
@@ -326,6 +355,16 @@ func splitPipes(pipes WeightedPagePipes, size int) []paginatedElement {
 	return split
 }
 
+func splitGroupCounts(counts PageGroupCounts, size int) []paginatedElement {
+	var split []paginatedElement
+	for low, j := 0, len(counts); low < j; low += size {
+		high := int(math.Min(float64(low+size), float64(len(counts))))
+		split = append(split, counts[low:high])
+	}
+
+	return split
+}
+
 func splitPageGroups(pageGroups PagesGroup, size int) []paginatedElement {
 
 	type keyPage struct {
@@ -376,9 +415,10 @@ func (p *Page) Paginator(options ...interface{}) (*Pager, error) {
 }
 
 // Paginator gets this PageOutput's paginator if it's already created.
-// If it's not, one will be created with all pages in Data["Pages"].
+// If it's not, one will be created with all pages listed on the node, read
+// from the page store a pager at a time.
 func (p *PageOutput) Paginator(options ...interface{}) (*Pager, error) {
-	if !p.IsNode() {
+	if !p.listsPages() {
 		return nil, fmt.Errorf("Paginators not supported for pages of type %q (%q)", p.Kind, p.title)
 	}
 	pagerSize, err := resolvePagerSize(p.s.Cfg, options...)
@@ -398,7 +438,12 @@ func (p *PageOutput) Paginator(options ...interface{}) (*Pager, error) {
 		if p.s.owner.IsMultihost() {
 			pathDescriptor.LangPrefix = ""
 		}
-		pagers, err := paginatePages(pathDescriptor, p.PageIds, pagerSize)
+		var seq interface{} = p.PageIds
+		if c := p.s.PageStore.nodeCursor(p.Page); c != nil {
+			seq = c
+		}
+
+		pagers, err := paginatePages(pathDescriptor, seq, pagerSize)
 
 		if err != nil {
 			initError = err
@@ -429,7 +474,7 @@ func (p *Page) Paginate(seq interface{}, options ...interface{}) (*Pager, error)
 // If it's not, one will be created with the qiven sequence.
 // Note that repeated calls will return the same result, even if the sequence is different.
 func (p *PageOutput) Paginate(seq interface{}, options ...interface{}) (*Pager, error) {
-	if !p.IsNode() {
+	if !p.listsPages() {
 		return nil, fmt.Errorf("Paginators not supported for pages of type %q (%q)", p.Kind, p.title)
 	}
 
@@ -513,6 +558,19 @@ func paginatePages(td targetPathDescriptor, seq interface{}, pagerSize int) (pag
 	} else if pipes, ok := seq.([]WeightedPagePipe); ok {
 		paginator, _ = newPaginatorFromPipes(pipes, pagerSize, urlFactory)
 
+	} else if counts, ok := seq.(PageGroupCounts); ok {
+		paginator, _ = newPaginatorFromGroupCounts(counts, pagerSize, urlFactory)
+
+	} else if c, ok := seq.(*storeCursor); ok {
+		paginator, _ = newPaginatorFromStoreCursor(c, pagerSize, urlFactory)
+
+	} else if sp, ok := seq.(StorePages); ok {
+		if c := sp.cursor(); c != nil {
+			paginator, _ = newPaginatorFromStoreCursor(c, pagerSize, urlFactory)
+		} else {
+			paginator, _ = newPaginatorFromPageIds(sp.PageIds(), pagerSize, urlFactory)
+		}
+
 	} else {
 		//pages, err := toPages(seq)
 		pages := seq.(PageIds)
@@ -576,6 +634,10 @@ func probablyEqualPageLists(a1 interface{}, a2 interface{}) bool {
 		return g1[0].Pages[0] == g2[0].Pages[0]
 	}
 
+	if sp1, ok := a1.(StorePages); ok {
+		return sp1.equal(a2.(StorePages))
+	}
+
 	p1, err1 := toPages(a1)
 	p2, err2 := toPages(a2)
 
@@ -627,6 +689,17 @@ func newPaginatorFromPipes(pipes WeightedPagePipes, size int, urlFactory paginat
 	return newPaginator(split, len(pipes), size, urlFactory)
 }
 
+func newPaginatorFromGroupCounts(counts PageGroupCounts, size int, urlFactory paginationURLFactory) (*paginator, error) {
+
+	if size <= 0 {
+		return nil, errors.New("Paginator size must be positive")
+	}
+
+	split := splitGroupCounts(counts, size)
+
+	return newPaginator(split, len(counts), size, urlFactory)
+}
+
 func newPaginatorFromPageGroups(pageGroups PagesGroup, size int, urlFactory paginationURLFactory) (*paginator, error) {
 
 	if size <= 0 {
@@ -0,0 +1,211 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// A storeCursor is a sorted query on a page store collection that yields
+// page ids. Paginators built on one only count the matches up front; the
+// ids of a pager are read with skip/limit when the pager is rendered.
+type storeCursor struct {
+	ps *PageStore
+
+	collection string
+	query      bson.M
+	sort       []string
+
+	// The document field that holds the page id.
+	idField string
+
+	limit int
+}
+
+// count returns the number of ids the cursor yields.
+func (c *storeCursor) count() int {
+	n, err := c.ps.MongoSession.DB("hugo").C(c.collection).Find(c.query).Count()
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	if c.limit > 0 && n > c.limit {
+		n = c.limit
+	}
+
+	return n
+}
+
+// pageIds returns the ids from index from up to, but not including, to.
+func (c *storeCursor) pageIds(from, to int) PageIds {
+	if c.limit > 0 && to > c.limit {
+		to = c.limit
+	}
+
+	pageIds := make(PageIds, 0, to-from)
+
+	if to <= from {
+		return pageIds
+	}
+
+	items := c.ps.MongoSession.DB("hugo").C(c.collection).Find(c.query).Sort(c.sort...).Select(bson.M{c.idField: 1}).Skip(from).Limit(to - from).Batch(to - from).Iter()
+
+	item := bson.M{}
+	for items.Next(&item) {
+		pageIds = append(pageIds, PageId(fmt.Sprint(item[c.idField])))
+		item = bson.M{}
+	}
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return pageIds
+}
+
+// cursor returns the store query of the collection, or nil if it is a fixed
+// list of ids.
+func (sp StorePages) cursor() *storeCursor {
+	if sp.byIds() {
+		return nil
+	}
+
+	return &storeCursor{
+		ps:         sp.ps,
+		collection: "pages",
+		query:      sp.filter(),
+		sort:       sp.sort,
+		idField:    "_id",
+		limit:      sp.limit,
+	}
+}
+
+// nodeCursor returns the store query of the pages listed on the node p, i.e.
+// what .Paginator paginates, or nil if p lists no pages.
+func (ps *PageStore) nodeCursor(p *Page) *storeCursor {
+	switch p.Kind {
+	case KindHome, KindSection, KindPage:
+		if sp, ok := p.Data["Pages"].(StorePages); ok {
+			return sp.cursor()
+		}
+		return nil
+	case KindTaxonomy:
+		plural, term, ok := taxonomyPluralTerm(p)
+		if !ok {
+			return nil
+		}
+		return &storeCursor{
+			ps:         ps,
+			collection: "weighted_pages",
+			query:      bson.M{"plural": plural, "key": term},
+			sort:       weightedPagesSort,
+			idField:    "pageid",
+		}
+	case KindTaxonomyTerm:
+		plural, ok := p.Data["Plural"].(string)
+		if !ok {
+			return nil
+		}
+		return &storeCursor{
+			ps:         ps,
+			collection: "weighted_pages",
+			query:      bson.M{"plural": plural},
+			sort:       []string{"_id"},
+			idField:    "pageid",
+		}
+	}
+
+	return nil
+}
+
+func taxonomyPluralTerm(p *Page) (string, string, bool) {
+	plural, ok1 := p.Data["Plural"].(string)
+	term, ok2 := p.Data["Term"].(string)
+	return plural, term, ok1 && ok2
+}
+
+// A storePager is the paginated element of a pager built on a storeCursor.
+// Its ids are read the first time they are asked for and dropped again with
+// release once the pager is rendered.
+type storePager struct {
+	cursor   *storeCursor
+	from, to int
+
+	mu  sync.Mutex
+	ids PageIds
+}
+
+func (sp *storePager) Len() int {
+	return sp.to - sp.from
+}
+
+func (sp *storePager) pageIds() PageIds {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.ids == nil {
+		sp.ids = sp.cursor.pageIds(sp.from, sp.to)
+	}
+
+	return sp.ids
+}
+
+func (sp *storePager) release() {
+	sp.mu.Lock()
+	sp.ids = nil
+	sp.mu.Unlock()
+}
+
+func splitStoreCursor(c *storeCursor, total, size int) []paginatedElement {
+	var split []paginatedElement
+	for low := 0; low < total; low += size {
+		high := low + size
+		if high > total {
+			high = total
+		}
+		split = append(split, &storePager{cursor: c, from: low, to: high})
+	}
+
+	return split
+}
+
+func newPaginatorFromStoreCursor(c *storeCursor, size int, urlFactory paginationURLFactory) (*paginator, error) {
+
+	if size <= 0 {
+		return nil, errors.New("Paginator size must be positive")
+	}
+
+	total := c.count()
+	split := splitStoreCursor(c, total, size)
+
+	return newPaginator(split, total, size, urlFactory)
+}
+
+// release drops the ids read for the pager, if any.
+func (p *Pager) release() {
+	if len(p.paginatedElements) == 0 {
+		return
+	}
+
+	if sp, ok := p.element().(*storePager); ok {
+		sp.release()
+	}
+}
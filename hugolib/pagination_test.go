@@ -42,6 +42,29 @@ func TestSplitPages(t *testing.T) {
 
 }
 
+func TestSplitStoreCursor(t *testing.T) {
+	t.Parallel()
+
+	c := &storeCursor{}
+	chunks := splitStoreCursor(c, 21, 5)
+	require.Equal(t, 5, len(chunks))
+
+	for i := 0; i < 4; i++ {
+		require.Equal(t, 5, chunks[i].Len())
+	}
+
+	lastChunk := chunks[4].(*storePager)
+	require.Equal(t, 1, lastChunk.Len())
+	require.Equal(t, 20, lastChunk.from)
+
+	require.Len(t, splitStoreCursor(c, 0, 5), 0)
+
+	p, err := newPaginator(chunks, 21, 5, nil)
+	require.NoError(t, err)
+	require.Equal(t, 5, p.TotalPages())
+	require.Equal(t, 1, p.Pagers()[4].NumberOfElements())
+}
+
 func TestSplitPageGroups(t *testing.T) {
 	t.Parallel()
 	s := newTestSite(t)
@@ -0,0 +1,194 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// The RocksDB key space of the files the pages of a build publish, see
+// claimOutputPaths.
+const outputPathKeySpace = "out"
+
+const (
+	pathCollisionsWarn = "warn"
+	pathCollisionsFail = "fail"
+)
+
+// How many path collisions are listed per build.
+const maxReportedPathCollisions = 50
+
+// decodePathCollisions reads the pathCollisions config, what to do when
+// two pages publish the same file: "warn" (the default) or "fail".
+func decodePathCollisions(in string) (string, error) {
+	switch in {
+	case "":
+		return pathCollisionsWarn, nil
+	case pathCollisionsWarn, pathCollisionsFail:
+		return in, nil
+	default:
+		return "", fmt.Errorf("pathCollisions must be %q or %q, got %q", pathCollisionsWarn, pathCollisionsFail, in)
+	}
+}
+
+// outputPath is a file published for a page.
+type outputPath struct {
+	Path   string `json:"-"`
+	PageId string `json:"id"`
+	Source string `json:"source,omitempty"`
+
+	// What of the page is published, e.g. "HTML", "HTML pager 2" or
+	// "AMP alias /old-url".
+	What string `json:"what"`
+
+	Render int64 `json:"render"`
+}
+
+func (o outputPath) String() string {
+	source := o.Source
+	if source == "" {
+		source = "no source file"
+	}
+	return fmt.Sprintf("%s of page %s (%s)", o.What, o.PageId, source)
+}
+
+// pathCollision is a file published for two pages, or twice for one.
+type pathCollision struct {
+	First  outputPath
+	Second outputPath
+}
+
+func (c pathCollision) String() string {
+	return fmt.Sprintf("%q is published for %s and for %s", c.First.Path, c.First, c.Second)
+}
+
+func cleanOutputPath(target string) string {
+	return strings.TrimPrefix(filepath.ToSlash(target), "/")
+}
+
+// outputPaths returns the files rendering p publishes: one per output
+// format, the pagers of list pages by the number of pages they list, and
+// the aliases.
+func (s *Site) outputPaths(p *Page) ([]outputPath, error) {
+	var (
+		paths        []outputPath
+		pagers       int
+		source       = filepath.ToSlash(p.Path())
+		paginatePath = s.Cfg.GetString("paginatePath")
+		aliases      = newAliasHandler(s.Tmpl, s.Log, false)
+	)
+
+	if size := s.Cfg.GetInt("paginate"); size > 0 && p.listsPages() && p.thinAction != thinActionRedirect {
+		pagers = (s.PageStore.pageIdsCount(p) + size - 1) / size
+	}
+
+	add := func(target, what string) {
+		paths = append(paths, outputPath{Path: cleanOutputPath(target), PageId: p.ID, Source: source, What: what, Render: s.owner.renderID})
+	}
+
+	for _, f := range p.outputFormats {
+		target, err := p.createTargetPath(f, false)
+		if err != nil {
+			return nil, err
+		}
+		add(target, f.Name)
+
+		if f.IsHTML {
+			for i := 1; i <= pagers; i++ {
+				target, err := p.createTargetPath(f, false, fmt.Sprintf("/%s/%d", paginatePath, i))
+				if err != nil {
+					return nil, err
+				}
+				add(target, fmt.Sprintf("%s pager %d", f.Name, i))
+			}
+
+			for _, a := range p.Aliases {
+				// Invalid aliases fail the rendering of aliases.
+				if target, err := aliases.targetPathAlias(s.aliasPath(p, f, a)); err == nil {
+					add(target, fmt.Sprintf("%s alias %s", f.Name, a))
+				}
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// pageIdsCount returns how many pages p lists, read from the store for
+// sections loaded without their page ids.
+func (ps *PageStore) pageIdsCount(p *Page) int {
+	if len(p.PageIds) > 0 {
+		return len(p.PageIds)
+	}
+
+	var ids []string
+	json.Unmarshal([]byte(ps.RDBGet(p.ID+"_PageIds")), &ids)
+
+	return len(ids)
+}
+
+// claimOutputPaths records the files published for a page, and returns
+// those already published for another page or for another output of the
+// same page in this render. Claims of the renders before are replaced.
+func (ps *PageStore) claimOutputPaths(paths []outputPath) []pathCollision {
+	var collisions []pathCollision
+
+	for _, o := range paths {
+		key := outputPathKeySpace + "_" + o.Path
+
+		if b := ps.RDBGet(key); b != "" {
+			var first outputPath
+			if err := json.Unmarshal([]byte(b), &first); err == nil && first.Render == o.Render {
+				first.Path = o.Path
+				collisions = append(collisions, pathCollision{First: first, Second: o})
+				continue
+			}
+		}
+
+		b, _ := json.Marshal(o)
+		ps.RDBSet(key, string(b))
+	}
+
+	return collisions
+}
+
+// reportPathCollisions logs the files published more than once, and fails
+// the build on them if pathCollisions is "fail".
+func (s *Site) reportPathCollisions(collisions []pathCollision) error {
+	if len(collisions) == 0 {
+		return nil
+	}
+
+	logger := s.Log.WARN
+	if s.pathCollisions == pathCollisionsFail {
+		logger = s.Log.ERROR
+	}
+
+	for i, c := range collisions {
+		if i == maxReportedPathCollisions {
+			logger.Printf("… and %d more path collisions", len(collisions)-i)
+			break
+		}
+		logger.Println(c)
+	}
+
+	if s.pathCollisions == pathCollisionsFail {
+		return fmt.Errorf("%d files would be published for more than one page", len(collisions))
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePathCollisions(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	v, err := decodePathCollisions("")
+	assert.NoError(err)
+	assert.Equal(pathCollisionsWarn, v)
+
+	v, err = decodePathCollisions("fail")
+	assert.NoError(err)
+	assert.Equal(pathCollisionsFail, v)
+
+	_, err = decodePathCollisions("ignore")
+	assert.Error(err)
+}
+
+func TestPathCollisionString(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	c := pathCollision{
+		First:  outputPath{Path: cleanOutputPath("/laptops/index.html"), PageId: "1", Source: "laptops/_index.md", What: "HTML"},
+		Second: outputPath{Path: cleanOutputPath("/laptops/index.html"), PageId: "2", What: "HTML alias /laptops/"},
+	}
+
+	assert.Equal(`"laptops/index.html" is published for HTML of page 1 (laptops/_index.md) and for HTML alias /laptops/ of page 2 (no source file)`, c.String())
+}
+
+func TestPathCollisionsBuild(t *testing.T) {
+	t.Parallel()
+
+	content := []string{
+		"a.md", "---\ntitle: A\nurl: /same/\n---\n",
+		"b.md", "---\ntitle: B\nurl: /same/\n---\n",
+	}
+
+	b := newTestSitesBuilder(t)
+	b.WithSimpleConfigFile().WithContent(content...)
+	b.CreateSites().Build(BuildCfg{})
+
+	b = newTestSitesBuilder(t)
+	b.WithConfigFile("toml", `
+baseURL = "http://example.com/"
+pathCollisions = "fail"
+`).WithContent(content...)
+	b.CreateSites().BuildFail(BuildCfg{})
+}
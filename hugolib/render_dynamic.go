@@ -0,0 +1,208 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/gohugoio/hugo/output"
+)
+
+// RenderedPage is a page, or pager, rendered on request.
+type RenderedPage struct {
+	Content   []byte
+	MediaType string
+
+	// Set instead of the content when the URL redirects, e.g. for thin
+	// pages and the first pager.
+	Redirect string
+}
+
+// RenderPath renders the page output with the given relative permalink,
+// for hugo server with renderDynamic set. It returns nil if no page has
+// it. It waits for builds and updates of pushed records in progress.
+func (h *HugoSites) RenderPath(urlPath string) (*RenderedPage, error) {
+	h.updateMu.RLock()
+	defer h.updateMu.RUnlock()
+
+	ps := h.Sites[0].PageStore
+
+	if strings.HasSuffix(urlPath, "/index.html") {
+		urlPath = strings.TrimSuffix(urlPath, "index.html")
+	}
+
+	pageNumber := 1
+
+	entry, found := ps.getPermalink(urlPath)
+	if !found {
+		base, n, ok := splitPagerPath(urlPath, h.Cfg.GetString("paginatePath"))
+		if !ok {
+			return nil, nil
+		}
+
+		if entry, found = ps.getPermalink(base); !found {
+			return nil, nil
+		}
+
+		if n == 1 {
+			return &RenderedPage{Redirect: base}, nil
+		}
+
+		pageNumber = n
+	}
+
+	for _, s := range h.Sites {
+		if s.Language.Lang == entry.Lang {
+			return s.renderPath(entry, pageNumber)
+		}
+	}
+
+	return nil, nil
+}
+
+// splitPagerPath splits the URL of a pager after the first, e.g.
+// /laptops/page/2/, into the URL of its page and its number.
+func splitPagerPath(urlPath, paginatePath string) (string, int, bool) {
+	if !strings.HasSuffix(urlPath, "/") {
+		return "", 0, false
+	}
+
+	parts := strings.Split(strings.TrimSuffix(urlPath, "/"), "/")
+	if len(parts) < 3 || parts[len(parts)-2] != paginatePath {
+		return "", 0, false
+	}
+
+	n, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil || n < 1 {
+		return "", 0, false
+	}
+
+	return strings.Join(parts[:len(parts)-2], "/") + "/", n, true
+}
+
+func (s *Site) renderPath(entry permalinkEntry, pageNumber int) (*RenderedPage, error) {
+	if !s.PageStore.pageExists(entry.PageId) {
+		return nil, nil
+	}
+
+	page := s.PageStore.getPageById(entry.PageId)
+
+	if page.thinAction == thinActionRedirect {
+		target, ok := s.thinRedirectURL(page)
+		if !ok {
+			return nil, fmt.Errorf("no parent listing to redirect %q to", page.pathOrTitle())
+		}
+		return &RenderedPage{Redirect: target}, nil
+	}
+
+	var (
+		f     output.Format
+		found bool
+	)
+
+	for _, pf := range page.outputFormats {
+		if pf.Name == entry.Format {
+			f, found = pf, true
+			break
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	page.rc = &siteRenderingContext{Format: f}
+	page.setContentInit(true)
+
+	pageOutput, err := newPageOutput(page, false, f)
+	if err != nil {
+		return nil, err
+	}
+	page.mainPageOutput = pageOutput
+
+	if err := pageOutput.renderResources(); err != nil {
+		s.Log.ERROR.Printf("Failed to render resources for page %q: %s", page, err)
+	}
+
+	targetPath, err := pageOutput.targetPath()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+
+	if f.Name == "RSS" {
+		if !s.isEnabled(kindRSS) {
+			return nil, nil
+		}
+
+		layouts, err := s.rssLayouts(pageOutput)
+		if err != nil {
+			return nil, err
+		}
+
+		if !s.renderXML(page.title, targetPath, pageOutput, &b, layouts...) {
+			return nil, fmt.Errorf("failed to render %q", targetPath)
+		}
+
+		return &RenderedPage{Content: b.Bytes(), MediaType: f.MediaType.Type()}, nil
+	}
+
+	var layouts []string
+
+	if page.selfLayout != "" {
+		layouts = []string{page.selfLayout}
+	} else if layouts, err = s.layouts(pageOutput); err != nil {
+		return nil, err
+	}
+
+	if pageNumber == 1 {
+		if !s.renderPage(targetPath, pageOutput, &b, layouts...) {
+			return nil, fmt.Errorf("failed to render %q", targetPath)
+		}
+		return &RenderedPage{Content: b.Bytes(), MediaType: f.MediaType.Type()}, nil
+	}
+
+	// The paginator is set up by the template of the first pager.
+	s.renderPage(targetPath, pageOutput, ioutil.Discard, layouts...)
+
+	if pageOutput.paginator == nil {
+		return nil, nil
+	}
+
+	pagers := pageOutput.paginator.Pagers()
+	if pageNumber > len(pagers) {
+		return nil, nil
+	}
+
+	pagerNode, err := pageOutput.pagerCopy(pagers[pageNumber-1])
+	if err != nil {
+		return nil, err
+	}
+
+	targetPath, err = pageOutput.targetPath(fmt.Sprintf("/%s/%d", s.Cfg.GetString("paginatePath"), pageNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.renderPage(targetPath, pagerNode, &b, layouts...) {
+		return nil, fmt.Errorf("failed to render %q", targetPath)
+	}
+
+	return &RenderedPage{Content: b.Bytes(), MediaType: f.MediaType.Type()}, nil
+}
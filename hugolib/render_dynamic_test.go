@@ -0,0 +1,155 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitPagerPath(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	for _, test := range []struct {
+		path       string
+		base       string
+		pageNumber int
+		ok         bool
+	}{
+		{"/laptops/page/2/", "/laptops/", 2, true},
+		{"/docs/laptops/lenovo/page/12/", "/docs/laptops/lenovo/", 12, true},
+		{"/page/3/", "/", 3, true},
+		{"/laptops/page/1/", "/laptops/", 1, true},
+		{"/laptops/page/2", "", 0, false},
+		{"/laptops/page/0/", "", 0, false},
+		{"/laptops/page/two/", "", 0, false},
+		{"/laptops/pages/2/", "", 0, false},
+		{"/laptops/", "", 0, false},
+	} {
+		base, n, ok := splitPagerPath(test.path, "page")
+		assert.Equal(test.ok, ok, test.path)
+		assert.Equal(test.base, base, test.path)
+		assert.Equal(test.pageNumber, n, test.path)
+	}
+}
+
+func TestRenderPath(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", `
+baseURL = "http://example.com/"
+paginate = 1
+renderDynamic = true
+`)
+	b.WithContent(
+		"products/p1.md", "---\ntitle: P1\nweight: 1\n---\n",
+		"products/p2.md", "---\ntitle: P2\nweight: 2\n---\n",
+	)
+	b.WithTemplates(
+		"_default/single.html", `Single: {{ .Title }}`,
+		"_default/list.html", `{{ $pager := .Paginate .Data.Pages.ByWeight }}Pager: {{ $pager.PageNumber }} {{ range $pager.Pages }}{{ .Title }}{{ end }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	// Nothing is rendered up front.
+	assert.False(b.CheckExists("public/products/p1/index.html"))
+
+	page, err := b.H.RenderPath("/products/p1/")
+	assert.NoError(err)
+	assert.NotNil(page)
+	assert.Equal("text/html", page.MediaType)
+	assert.Contains(string(page.Content), "Single: P1")
+
+	page, err = b.H.RenderPath("/products/page/2/")
+	assert.NoError(err)
+	assert.NotNil(page)
+	assert.Contains(string(page.Content), "Pager: 2 P2")
+
+	page, err = b.H.RenderPath("/products/page/1/")
+	assert.NoError(err)
+	assert.Equal("/products/", page.Redirect)
+
+	page, err = b.H.RenderPath("/nope/")
+	assert.NoError(err)
+	assert.Nil(page)
+}
+
+func TestRenderPathDuringUpdate(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "hugo-render")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "products.jsonl")
+	assert.NoError(ioutil.WriteFile(source, []byte(`{"sku": "p1", "title": "One", "tags": ["a"]}`+"\n"), 0644))
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", fmt.Sprintf(`
+baseURL = "http://example.com/"
+renderDynamic = true
+
+[taxonomies]
+tag = "tags"
+
+[[ingest]]
+name = "products"
+source = %q
+section = "products"
+slug = "{{ .sku }}"
+`, source))
+	b.WithTemplates(
+		"_default/single.html", `Single: {{ .Title }}`,
+		"_default/list.html", `{{ range $k, $v := .Site.Taxonomies.tags }}{{ $k }}={{ $v.Count }}|{{ end }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	// Updates write the taxonomies the pages rendered on request read, run
+	// with -race.
+	var (
+		wg        sync.WaitGroup
+		updateErr error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5 && updateErr == nil; i++ {
+			_, updateErr = b.H.Update("products", []map[string]interface{}{
+				{"sku": "p1", "title": "One", "tags": []interface{}{"a"}},
+			})
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		page, err := b.H.RenderPath("/tags/a/")
+		assert.NoError(err)
+		assert.NotNil(page)
+		assert.Contains(string(page.Content), "a=1|")
+	}
+
+	wg.Wait()
+	assert.NoError(updateErr)
+}
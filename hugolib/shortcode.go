@@ -366,11 +366,12 @@ func (s *shortcodeHandler) updateDelta() bool {
 		s.contentShortcodes = createShortcodeRenderers(s.shortcodes, s.p.withoutContent())
 	})
 
-	if !s.p.shouldRenderTo(s.p.s.rc.Format) {
+	of := s.p.renderingContext().Format
+
+	if !s.p.shouldRenderTo(of) {
 		// TODO(bep) add test for this re translations
 		return false
 	}
-	of := s.p.s.rc.Format
 	contentShortcodes := s.contentShortcodesForOutputFormat(of)
 
 	if s.contentShortcodesDelta == nil || s.contentShortcodesDelta.Len() == 0 {
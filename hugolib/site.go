@@ -40,9 +40,7 @@ import (
 	"github.com/markbates/inflect"
 	"golang.org/x/net/context"
 
-	"github.com/adam-hanna/arrayOperations"
 	"github.com/fsnotify/fsnotify"
-	"github.com/globalsign/mgo/bson"
 	bp "github.com/gohugoio/hugo/bufferpool"
 	"github.com/gohugoio/hugo/deps"
 	"github.com/gohugoio/hugo/helpers"
@@ -151,6 +149,31 @@ type Site struct {
 
 	relatedDocsHandler *relatedDocsHandler
 
+	// The faceted navigation of listing pages.
+	facets FacetsConfig
+
+	// Nil unless facet combination pages are generated.
+	facetCombinations *FacetCombinationsConfig
+
+	// What to do with generated list pages with too few pages.
+	thinPages ThinPagesConfig
+
+	canonical CanonicalConfig
+
+	variants VariantsConfig
+
+	// The params pages can be looked up by, see GetPageBy.
+	lookupKeys []string
+
+	// The feeds of records built into pages, see IngestSourceConfig.
+	ingestSources []IngestSourceConfig
+
+	// The data files joined to the pages, see EnrichSourceConfig.
+	enrichSources []EnrichSourceConfig
+
+	// "warn" or "fail", see reportPathCollisions.
+	pathCollisions string
+
 	PageStore *PageStore
 }
 
@@ -190,6 +213,15 @@ func (s *Site) reset() *Site {
 		disabledKinds:       s.disabledKinds,
 		titleFunc:           s.titleFunc,
 		relatedDocsHandler:  newSearchIndexHandler(s.relatedDocsHandler.cfg),
+		facets:              s.facets,
+		facetCombinations:   s.facetCombinations,
+		thinPages:           s.thinPages,
+		canonical:           s.canonical,
+		variants:            s.variants,
+		lookupKeys:          s.lookupKeys,
+		ingestSources:       s.ingestSources,
+		enrichSources:       s.enrichSources,
+		pathCollisions:      s.pathCollisions,
 		outputFormats:       s.outputFormats,
 		rc:                  s.rc,
 		outputFormatsConfig: s.outputFormatsConfig,
@@ -263,6 +295,51 @@ func newSite(cfg deps.DepsCfg) (*Site, error) {
 		}
 	}
 
+	facets, err := decodeFacetsConfig(cfg.Language.Get("facets"), cfg.Language.GetStringMapString("taxonomies"))
+	if err != nil {
+		return nil, err
+	}
+
+	facetCombinations, err := decodeFacetCombinationsConfig(cfg.Language.Get("facetCombinations"))
+	if err != nil {
+		return nil, err
+	}
+
+	thinPages, err := decodeThinPagesConfig(cfg.Language.Get("thinPages"), cfg.Language.GetStringMapString("taxonomies"))
+	if err != nil {
+		return nil, err
+	}
+
+	canonical, err := decodeCanonicalConfig(cfg.Language.Get("canonical"))
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := decodeVariantsConfig(cfg.Language.Get("variants"))
+	if err != nil {
+		return nil, err
+	}
+
+	lookupKeys, err := decodeLookupKeys(cfg.Language.Get("lookupKeys"))
+	if err != nil {
+		return nil, err
+	}
+
+	ingestSources, err := decodeIngestConfig(cfg.Language.Get("ingest"))
+	if err != nil {
+		return nil, err
+	}
+
+	enrichSources, err := decodeEnrichConfig(cfg.Language.Get("enrich"))
+	if err != nil {
+		return nil, err
+	}
+
+	pathCollisions, err := decodePathCollisions(cfg.Language.GetString("pathCollisions"))
+	if err != nil {
+		return nil, err
+	}
+
 	titleFunc := helpers.GetTitleFunc(cfg.Language.GetString("titleCaseStyle"))
 
 	frontMatterHandler, err := pagemeta.NewFrontmatterHandler(cfg.Logger, cfg.Cfg)
@@ -278,6 +355,15 @@ func newSite(cfg deps.DepsCfg) (*Site, error) {
 		disabledKinds:       disabledKinds,
 		titleFunc:           titleFunc,
 		relatedDocsHandler:  newSearchIndexHandler(relatedContentConfig),
+		facets:              facets,
+		facetCombinations:   facetCombinations,
+		thinPages:           thinPages,
+		canonical:           canonical,
+		variants:            variants,
+		lookupKeys:          lookupKeys,
+		ingestSources:       ingestSources,
+		enrichSources:       enrichSources,
+		pathCollisions:      pathCollisions,
 		outputFormats:       outputFormats,
 		rc:                  &siteRenderingContext{output.HTMLFormat},
 		outputFormatsConfig: siteOutputFormatsConfig,
@@ -289,7 +375,9 @@ func newSite(cfg deps.DepsCfg) (*Site, error) {
 	s.Info = newSiteInfo(siteBuilderCfg{s: s, pageCollections: c, language: s.Language})
 	sitePageStore.Cfg = cfg.Cfg
 
-	sitePageStore.initPageStore(s)
+	if err := sitePageStore.initPageStore(s); err != nil {
+		return nil, err
+	}
 
 	return s, nil
 
@@ -379,7 +467,7 @@ func (s SiteInfos) First() *SiteInfo {
 }
 
 type SiteInfo struct {
-	Taxonomies TaxonomyList
+	Taxonomies StoreTaxonomyList
 	Authors    AuthorList
 	Social     SiteSocial
 	*PageCollections
@@ -506,7 +594,7 @@ func (s *SiteInfo) refLink(ref string, page *Page, relative bool, outputFormat s
 	var link string
 
 	if refURL.Path != "" {
-		target := s.getPage(KindPage, refURL.Path)
+		target = s.s.PageStore.resolveRef(s.s.Language.Lang, refURL.Path)
 
 		if target == nil {
 			return "", fmt.Errorf("No page found with path or logical name \"%s\".\n", refURL.Path)
@@ -990,39 +1078,34 @@ func (s *Site) setupSitePages() {
 	s.Info.LastChange = siteLastChange
 }
 
-func (s *Site) render(config *BuildCfg, outFormatIdx int) (err error) {
+func (s *Site) render(config *BuildCfg) (err error) {
 
-	if outFormatIdx == 0 {
-		if err = s.preparePages(); err != nil {
+	if err = s.preparePages(); err != nil {
+		return
+	}
+	s.timerStep("prepare pages")
+
+	// Note that even if disableAliases is set, the aliases themselves are
+	// preserved on page. The motivation with this is to be able to generate
+	// 301 redirects in a .htacess file and similar using a custom output format.
+	if !s.Cfg.GetBool("disableAliases") {
+		// Aliases must be rendered before pages.
+		// Some sites, Hugo docs included, have faulty alias definitions that point
+		// to itself or another real page. These will be overwritten in the next
+		// step.
+		if err = s.renderAliases(); err != nil {
 			return
 		}
-		s.timerStep("prepare pages")
-
-		// Note that even if disableAliases is set, the aliases themselves are
-		// preserved on page. The motivation with this is to be able to generate
-		// 301 redirects in a .htacess file and similar using a custom output format.
-		if !s.Cfg.GetBool("disableAliases") {
-			// Aliases must be rendered before pages.
-			// Some sites, Hugo docs included, have faulty alias definitions that point
-			// to itself or another real page. These will be overwritten in the next
-			// step.
-			if err = s.renderAliases(); err != nil {
-				return
-			}
-			s.timerStep("render and write aliases")
-		}
-
+		s.timerStep("render and write aliases")
 	}
 
-	if err = s.renderPages(config); err != nil {
-		return
-	}
-
-	s.timerStep("render and write pages")
+	if !s.Cfg.GetBool("renderDynamic") {
+		// Otherwise pages are rendered on request, see HugoSites.RenderPath.
+		if err = s.renderPages(config); err != nil {
+			return
+		}
 
-	// TODO(bep) render consider this, ref. render404 etc.
-	if outFormatIdx > 0 {
-		return
+		s.timerStep("render and write pages")
 	}
 
 	if err = s.renderSitemap(); err != nil {
@@ -1335,7 +1418,12 @@ func (s *Site) readAndProcessContent(filenames ...string) error {
 		if v.Language.Disabled {
 			continue
 		}
+		enricher, err := v.newPageEnricher()
+		if err != nil {
+			return err
+		}
 		proc := newSiteContentProcessor(ctx, len(filenames) > 0, v)
+		proc.enricher = enricher
 		contentProcessors[k] = proc
 		if k == defaultContentLanguage {
 			defaultContentProcessor = proc
@@ -1448,90 +1536,75 @@ func (s *SiteInfo) createNodeMenuEntryURL(in string) string {
 	return menuEntryURL
 }
 
+// assembleMenus collects the menu entries of the config, the sections and
+// the pages in one pass over the pages with menus, stores them and builds
+// .Site.Menus from them.
 func (s *Site) assembleMenus() {
-	s.Menus = Menus{}
-
 	type twoD struct {
 		MenuName, EntryName string
 	}
-	flat := map[twoD]*MenuEntry{}
-	children := map[twoD]Menu{}
+
+	var (
+		entries []storedMenuEntry
+		flat    = make(map[twoD]bool)
+	)
+
+	add := func(name string, me *MenuEntry) bool {
+		key := twoD{name, me.KeyName()}
+		if flat[key] {
+			return false
+		}
+		flat[key] = true
+		entries = append(entries, newStoredMenuEntry(name, me))
+		return true
+	}
 
 	// add menu entries from config to flat hash
 	menuConfig := s.getMenusFromConfig()
 	for name, menu := range menuConfig {
 		for _, me := range *menu {
-			flat[twoD{name, me.KeyName()}] = me
+			add(name, me)
 		}
 	}
 
 	sectionPagesMenu := s.Info.sectionPagesMenu
-	//pages := s.Pages
-
-	if sectionPagesMenu != "" {
-		//for _, p := range pages {
-		s.PageStore.eachPages(func(p *Page) (error) {
-			if p.Kind == KindSection {
-				// From Hugo 0.22 we have nested sections, but until we get a
-				// feel of how that would work in this setting, let us keep
-				// this menu for the top level only.
-				id := p.Section()
-				if _, ok := flat[twoD{sectionPagesMenu, id}]; ok {
-					return nil
-				}
 
-				me := MenuEntry{Identifier: id,
-					Name:   p.LinkTitle(),
-					Weight: p.Weight,
-					URL:    p.RelPermalink()}
-				flat[twoD{sectionPagesMenu, me.KeyName()}] = &me
-			}
-
-			return nil
-		}, true, false, false, false)
-	}
+	var (
+		sections []*MenuEntry
+		pages    []PageMenus
+	)
 
-	// Add menu entries provided by pages
-	//for _, p := range pages {
-	s.PageStore.eachPages(func(p *Page) (error) {
-		for name, me := range p.Menus() {
-			if _, ok := flat[twoD{name, me.KeyName()}]; ok {
-				s.Log.ERROR.Printf("Two or more menu items have the same name/identifier in Menu %q: %q.\nRename or set an unique identifier.\n", name, me.KeyName())
-				continue
-			}
-			flat[twoD{name, me.KeyName()}] = me
+	s.PageStore.eachMenuPage(s.Language.Lang, sectionPagesMenu != "", func(p *Page) {
+		if sectionPagesMenu != "" && p.Kind == KindSection && len(p.sections) == 1 {
+			// From Hugo 0.22 we have nested sections, but until we get a
+			// feel of how that would work in this setting, let us keep
+			// this menu for the top level only.
+			sections = append(sections, &MenuEntry{Identifier: p.Section(),
+				Name:   p.LinkTitle(),
+				Weight: p.Weight,
+				URL:    p.RelPermalink()})
 		}
-		return nil
-
-	}, true, false, false, false)
 
-	// Create Children Menus First
-	for _, e := range flat {
-		if e.Parent != "" {
-			children[twoD{e.Menu, e.Parent}] = children[twoD{e.Menu, e.Parent}].add(e)
+		if menus := p.Menus(); len(menus) > 0 {
+			pages = append(pages, menus)
 		}
-	}
+	})
 
-	// Placing Children in Parents (in flat)
-	for p, childmenu := range children {
-		_, ok := flat[twoD{p.MenuName, p.EntryName}]
-		if !ok {
-			// if parent does not exist, create one without a URL
-			flat[twoD{p.MenuName, p.EntryName}] = &MenuEntry{Name: p.EntryName, URL: ""}
-		}
-		flat[twoD{p.MenuName, p.EntryName}].Children = childmenu
+	for _, me := range sections {
+		add(sectionPagesMenu, me)
 	}
 
-	// Assembling Top Level of Tree
-	for menu, e := range flat {
-		if e.Parent == "" {
-			_, ok := s.Menus[menu.MenuName]
-			if !ok {
-				s.Menus[menu.MenuName] = &Menu{}
+	// Add menu entries provided by pages
+	for _, menus := range pages {
+		for name, me := range menus {
+			if !add(name, me) {
+				s.Log.ERROR.Printf("Two or more menu items have the same name/identifier in Menu %q: %q.\nRename or set an unique identifier.\n", name, me.KeyName())
 			}
-			*s.Menus[menu.MenuName] = s.Menus[menu.MenuName].add(e)
 		}
 	}
+
+	s.PageStore.storeMenuEntries(s.Language.Lang, entries)
+	s.loadMenus()
 }
 
 func (s *Site) getTaxonomyKey(key string) string {
@@ -1564,55 +1637,60 @@ func (s *Site) assembleTaxonomies() {
 		s.taxonomiesPluralSingular[plural] = singular
 
 		s.PageStore.eachPages(func(p *Page) (error) {
-			vals := p.getParam(plural, !s.Info.preserveTaxonomyNames)
-			weight := p.getParamToLower(plural + "_weight")
-			if weight == nil {
-				weight = 0
-			}
-
-			if vals != nil {
-				if v, ok := vals.([]string); ok {
-					for _, idx := range v {
-						page := Page{
-							ID: p.ID,
-						}
+			s.assignTaxonomyTerms(plural, p)
+			return nil
+		}, false, false, false, false)
+	}
 
-						x := WeightedPage{weight.(int), &page}
+	// Term pages are sorted by <plural>_weight when read from the store,
+	// so all that is left is to count the pages per term.
+	s.PageStore.materializeTaxonomyTerms()
 
-						key := s.getTaxonomyKey(idx)
-						s.Taxonomies[plural].add(key)
-						s.PageStore.AddWeightedPageIds(plural, key, x)
+	s.Info.Taxonomies = make(StoreTaxonomyList)
+	for plural := range s.Taxonomies {
+		s.Info.Taxonomies[plural] = s.PageStore.loadTaxonomy(plural)
+	}
+}
 
-						if s.Info.preserveTaxonomyNames {
-							// Need to track the original
-							s.taxonomiesOrigKey[fmt.Sprintf("%s-%s", plural, s.PathSpec.MakePathSanitized(idx))] = idx
-						}
-					}
-				} else if v, ok := vals.(string); ok {
-					x := WeightedPage{weight.(int), p}
+// assignTaxonomyTerms adds p to the terms of the given taxonomy in its
+// params.
+func (s *Site) assignTaxonomyTerms(plural string, p *Page) {
+	vals := p.getParam(plural, !s.Info.preserveTaxonomyNames)
+	weight := p.getParamToLower(plural + "_weight")
+	if weight == nil {
+		weight = 0
+	}
 
-					key := s.getTaxonomyKey(v)
-					s.Taxonomies[plural].add(key)
+	if vals != nil {
+		if v, ok := vals.([]string); ok {
+			for _, idx := range v {
+				x := WeightedPage{weight.(int), p}
 
-					s.PageStore.AddWeightedPageIds(plural, key, x)
+				key := s.getTaxonomyKey(idx)
+				s.Taxonomies[plural].add(key)
+				s.PageStore.AddWeightedPageIds(plural, key, x)
 
-					if s.Info.preserveTaxonomyNames {
-						// Need to track the original
-						s.taxonomiesOrigKey[fmt.Sprintf("%s-%s", plural, s.PathSpec.MakePathSanitized(v))] = v
-					}
-				} else {
-					s.Log.ERROR.Printf("Invalid %s in %s\n", plural, p.File.Path())
+				if s.Info.preserveTaxonomyNames {
+					// Need to track the original
+					s.taxonomiesOrigKey[fmt.Sprintf("%s-%s", plural, s.PathSpec.MakePathSanitized(idx))] = idx
 				}
 			}
-			return nil
-		}, false, false, false, false)
-		//TODO David Sorting should work
-		//for k := range s.Taxonomies[plural] {
-		//	s.Taxonomies[plural][k].Sort()
-		//}
-	}
+		} else if v, ok := vals.(string); ok {
+			x := WeightedPage{weight.(int), p}
+
+			key := s.getTaxonomyKey(v)
+			s.Taxonomies[plural].add(key)
 
-	s.Info.Taxonomies = s.Taxonomies
+			s.PageStore.AddWeightedPageIds(plural, key, x)
+
+			if s.Info.preserveTaxonomyNames {
+				// Need to track the original
+				s.taxonomiesOrigKey[fmt.Sprintf("%s-%s", plural, s.PathSpec.MakePathSanitized(v))] = v
+			}
+		} else {
+			s.Log.ERROR.Printf("Invalid %s in %s\n", plural, p.File.Path())
+		}
+	}
 }
 
 // Prepare site for a new full build.
@@ -1654,7 +1732,12 @@ func (s *Site) layouts(p *PageOutput) ([]string, error) {
 }
 
 func (s *Site) preparePages() error {
-	var errors []error
+	var (
+		errors     []error
+		collisions []pathCollision
+	)
+
+	s.PageStore.storePositions(s.Language.Lang)
 
 	s.PageStore.eachPages(func(p *Page) (error) {
 		if err := p.prepareLayouts(); err != nil {
@@ -1668,18 +1751,35 @@ func (s *Site) preparePages() error {
 		//	s.PageStore.setPagePermalinkByPageHumanId(p.params["page_human_id"].(string), p.Permalink())
 		//}
 
-		if p.params["page_human_id"] != nil {
-			s.PageStore.setLitePageById("lite", p.params["page_human_id"].(string), p)
-			s.PageStore.setLitePageById("id", p.ID, p)
+		if s.multilingualEnabled() {
+			p.translationsIds = s.PageStore.translationIds(p)
+		}
+
+		s.PageStore.loadPosition(p)
+
+		s.PageStore.setLitePages(p, s.lookupKeys)
+
+		if paths, err := s.outputPaths(p); err != nil {
+			errors = append(errors, err)
+		} else {
+			collisions = append(collisions, s.PageStore.claimOutputPaths(paths)...)
 		}
 
 		return nil
 	}, true, false, true, false)
 
+	s.PageStore.markVariantMasters(s.Language.Lang, nil)
+
+	s.reportDuplicateLookupValues()
+
 	if len(errors) != 0 {
 		return fmt.Errorf("Prepare pages failed: %.100q…", errors)
 	}
 
+	if err := s.reportPathCollisions(collisions); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -1761,18 +1861,29 @@ func (s *Site) permalink(link string) string {
 
 func (s *Site) renderAndWriteXML(statCounter *uint64, name string, dest string, d interface{}, layouts ...string) error {
 	s.Log.DEBUG.Printf("Render XML for %q to %q", name, dest)
+
+	outBuffer := bp.GetBuffer()
+	defer bp.PutBuffer(outBuffer)
+
+	if !s.renderXML(name, dest, d, outBuffer, layouts...) {
+		return nil
+	}
+
+	return s.publish(statCounter, dest, outBuffer)
+}
+
+// renderXML renders d into w as renderAndWriteXML would write it to dest.
+// It returns false if it failed.
+func (s *Site) renderXML(name string, dest string, d interface{}, w io.Writer, layouts ...string) bool {
 	renderBuffer := bp.GetBuffer()
 	defer bp.PutBuffer(renderBuffer)
 	renderBuffer.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\" standalone=\"yes\" ?>\n")
 
 	if err := s.renderForLayouts(name, d, renderBuffer, layouts...); err != nil {
 		helpers.DistinctWarnLog.Println(err)
-		return nil
+		return false
 	}
 
-	outBuffer := bp.GetBuffer()
-	defer bp.PutBuffer(outBuffer)
-
 	var path []byte
 	if s.Info.relativeURLs {
 		path = []byte(helpers.GetDottedRelativePath(dest))
@@ -1784,31 +1895,54 @@ func (s *Site) renderAndWriteXML(statCounter *uint64, name string, dest string,
 		path = []byte(s)
 	}
 	transformer := transform.NewChain(transform.AbsURLInXML)
-	if err := transformer.Apply(outBuffer, renderBuffer, path); err != nil {
+	if err := transformer.Apply(w, renderBuffer, path); err != nil {
 		helpers.DistinctErrorLog.Println(err)
+		return false
+	}
+
+	return true
+
+}
+
+func (s *Site) renderAndWritePage(statCounter *uint64, name string, dest string, p *PageOutput, layouts ...string) error {
+	outBuffer := bp.GetBuffer()
+	defer bp.PutBuffer(outBuffer)
+
+	if !s.renderPage(dest, p, outBuffer, layouts...) {
 		return nil
 	}
 
+	if s.Cfg.GetBool("gzip") {
+
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+
+		string2 := outBuffer.String()
+
+		gz.Write([]byte(string2))
+		gz.Close()
+		return s.publish(statCounter, dest, &b)
+	}
+
 	return s.publish(statCounter, dest, outBuffer)
 
 }
 
-func (s *Site) renderAndWritePage(statCounter *uint64, name string, dest string, p *PageOutput, layouts ...string) error {
+// renderPage renders p into w as renderAndWritePage would write it to
+// dest. It returns false if it failed or rendered nothing.
+func (s *Site) renderPage(dest string, p *PageOutput, w io.Writer, layouts ...string) bool {
 	renderBuffer := bp.GetBuffer()
 	defer bp.PutBuffer(renderBuffer)
 
 	if err := s.renderForLayouts(p.Kind, p, renderBuffer, layouts...); err != nil {
 		helpers.DistinctWarnLog.Println(err)
-		return nil
+		return false
 	}
 
 	if renderBuffer.Len() == 0 {
-		return nil
+		return false
 	}
 
-	outBuffer := bp.GetBuffer()
-	defer bp.PutBuffer(outBuffer)
-
 	transformLinks := transform.NewEmptyTransforms()
 
 	isHTML := p.outputFormat.IsHTML
@@ -1843,25 +1977,12 @@ func (s *Site) renderAndWritePage(statCounter *uint64, name string, dest string,
 	}
 
 	transformer := transform.NewChain(transformLinks...)
-	if err := transformer.Apply(outBuffer, renderBuffer, path); err != nil {
+	if err := transformer.Apply(w, renderBuffer, path); err != nil {
 		helpers.DistinctErrorLog.Println(err)
-		return nil
-	}
-
-	if s.Cfg.GetBool("gzip") {
-
-		var b bytes.Buffer
-		gz := gzip.NewWriter(&b)
-
-		string2 := outBuffer.String()
-
-		gz.Write([]byte(string2))
-		gz.Close()
-		return s.publish(statCounter, dest, &b)
+		return false
 	}
 
-	return s.publish(statCounter, dest, outBuffer)
-
+	return true
 }
 
 func (s *Site) renderForLayouts(name string, d interface{}, w io.Writer, layouts ...string) (err error) {
@@ -2027,107 +2148,89 @@ func (siteInfo *SiteInfo) GetPagesById(pageIds []PageId) Pages {
 }
 
 func (siteInfo *SiteInfo) RegularPageIds() PageIds {
-	return siteInfo.s.PageStore.getPageIds(bson.M{"kind": "page"}, []string{"-params.publishdate"})
+	return siteInfo.s.PageStore.queryPages(PageQuery{
+		Conditions: []PageQueryCondition{{Field: "kind", Op: "eq", Value: KindPage}},
+		Sort:       []PageQuerySort{{Field: "params.publishdate", Desc: true}},
+	}).PageIds()
 }
 
 func (siteInfo *SiteInfo) AllPageIds() PageIds {
-	return siteInfo.s.PageStore.getPageIds(bson.M{}, []string{"-_id"})
+	return siteInfo.s.PageStore.queryPages(PageQuery{
+		Sort: []PageQuerySort{{Field: "id", Desc: true}},
+	}).PageIds()
 }
 
 func (siteInfo *SiteInfo) RegularPageIdsBySection(section string, sortField string) PageIds {
-	return siteInfo.s.PageStore.getPageIds(bson.M{"kind": "page", "sections.0": section}, []string{sortField})
+	q := PageQuery{
+		Conditions: []PageQueryCondition{
+			{Field: "kind", Op: "eq", Value: KindPage},
+			{Field: "section", Op: "eq", Value: section},
+		},
+	}
+	if sortField != "" {
+		q.Sort = []PageQuerySort{{Field: strings.TrimPrefix(sortField, "-"), Desc: strings.HasPrefix(sortField, "-")}}
+	}
+	return siteInfo.s.PageStore.queryPages(q).PageIds()
 }
 
 func (siteInfo *SiteInfo) GetTaxonomiesByCount(plural string) []WeightedPagePipe {
 	return siteInfo.s.PageStore.taxonomyTermsByCount(plural)
 }
 
+// GetTaxonomiesWithParamByCount returns the terms of the taxonomy with the
+// number of pages in them whose param key is value, most pages first.
+//
+// These used to match the params on the weighted pages, which were never
+// stored there, so they found no terms. The pages are counted in all
+// languages, as the weighted pages were, and under their normalized term,
+// as in .Site.Taxonomies.
 func (siteInfo *SiteInfo) GetTaxonomiesWithParamByCount(plural string, key string, value interface{}) []WeightedPagePipe {
-	paramKey := "params." + key
-	return siteInfo.s.PageStore.taxonomyTermsWithBsonMByCount(bson.M{"plural": plural, paramKey: value})
+	return siteInfo.taxonomyTermsByCountWhere(plural, PageQueryCondition{Field: "params." + key, Op: "eq", Value: value})
 }
 
+// GetTaxonomiesWithParamValueByCount is GetTaxonomiesWithParamByCount for a
+// param that holds a list, of which value is one.
 func (siteInfo *SiteInfo) GetTaxonomiesWithParamValueByCount(plural string, key string, value interface{}) []WeightedPagePipe {
-	paramKey := "params." + key
-	values := make([]interface{}, 0)
-	values = append(values, value)
-	return siteInfo.s.PageStore.taxonomyTermsWithBsonMByCount(bson.M{"plural": plural, paramKey: bson.M{"$in": values}})
-}
-
-func (siteInfo *SiteInfo) RegularPagesByParams(key string, value interface{}) PageIds {
-	paramKey := "params." + key
-	return siteInfo.s.PageStore.getPageIds(bson.M{"kind": "page", paramKey: value}, []string{"+params.title"})
-}
-
-type SearchTerm struct {
-	ID    string
-	Label string
-	Count int
-}
-
-var searchKeysTranslateTable = map[string]string{
-	"brands":  "מותגים",
-	"prices":  "מחירים",
-	"ratings": "דירוגים",
-	"tax":     "מיסים",
+	return siteInfo.taxonomyTermsByCountWhere(plural, PageQueryCondition{Field: "params." + key, Op: "in", Value: []interface{}{value}})
 }
 
-func (p *Page) GetSearchesByTerm() map[string][]SearchTerm {
-	plural := p.Data["Plural"].(string)
-	term := p.Data["Term"].(string)
+// taxonomyTermsByCountWhere counts the pages per term of the taxonomy
+// among the pages matching cond. The weighted pages hold no params, so the
+// terms are counted on the pages themselves.
+func (siteInfo *SiteInfo) taxonomyTermsByCountWhere(plural string, cond PageQueryCondition) []WeightedPagePipe {
+	counts := siteInfo.s.PageStore.queryPages(PageQuery{Conditions: []PageQueryCondition{cond}}).CountBy("params." + plural)
 
-	var cardinality int
-	pluralLabel := make([]string, 0)
-	termArray := make([]string, 0)
+	byKey := make(map[string]int)
+	keys := make([]string, 0, len(counts))
 
-	keyPathArray := strings.Split(term, "--")
-	values := make([]interface{}, 0)
-
-	if plural == "searches" {
-		cardinality = len(keyPathArray)
-		pluralLabel = append(pluralLabel, strings.Split(keyPathArray[0], "-")...)
-		termArray = append(termArray, keyPathArray[1:]...)
-		for _, x := range termArray {
-			values = append(values, x)
+	for _, c := range counts {
+		key := siteInfo.s.getTaxonomyKey(cast.ToString(c.Key))
+		if _, found := byKey[key]; !found {
+			keys = append(keys, key)
 		}
-	} else {
-		cardinality = len(keyPathArray) + 1
-		pluralLabel = append(pluralLabel, plural)
-		termArray = append(termArray, term)
-		values = append(values, term)
+		byKey[key] += c.Count
 	}
 
-	pipes := p.s.PageStore.taxonomyTermsWithBsonMByCount(bson.M{"plural": "searches", "cardinality": cardinality, "searchkeys": bson.M{"$all": values}})
-
-	var searchTermsMap = make(map[string][]SearchTerm)
-
-	for _, pipe := range pipes {
-		splitSearchLabel := strings.Split(pipe.SearchLabel, "-")
-
-		searchLabelDiff, _ := arrayOperations.Difference(splitSearchLabel, pluralLabel)
-		effectiveSearchLabel := searchLabelDiff.Interface().([]string)[0]
-
-		termDiff, _ := arrayOperations.Difference(pipe.SearchKeys, termArray)
-		effectiveKey := termDiff.Interface().([]string)[0]
+	pipes := make([]WeightedPagePipe, len(keys))
+	for i, key := range keys {
+		pipes[i] = WeightedPagePipe{ID: key, Count: byKey[key]}
+	}
 
-		translatedKey := searchKeysTranslateTable[effectiveSearchLabel]
+	sort.SliceStable(pipes, func(i, j int) bool {
+		return pipes[i].Count > pipes[j].Count
+	})
 
-		if len(searchTermsMap[translatedKey]) > 0 {
-			searchTermsMap[translatedKey] = append(searchTermsMap[translatedKey], SearchTerm{
-				ID:    pipe.ID,
-				Count: pipe.Count,
-				Label: effectiveKey,
-			})
-		} else {
-			searchTermsMap[translatedKey] = []SearchTerm{{
-				ID:    pipe.ID,
-				Count: pipe.Count,
-				Label: effectiveKey,
-			}}[:]
-		}
-	}
+	return pipes
+}
 
-	return searchTermsMap
+func (siteInfo *SiteInfo) RegularPagesByParams(key string, value interface{}) PageIds {
+	return siteInfo.s.PageStore.queryPages(PageQuery{
+		Conditions: []PageQueryCondition{
+			{Field: "kind", Op: "eq", Value: KindPage},
+			{Field: "params." + key, Op: "eq", Value: value},
+		},
+		Sort: []PageQuerySort{{Field: "params.title"}},
+	}).PageIds()
 }
 
 func (siteInfo *SiteInfo) GetHomePage() *Page {
@@ -76,10 +76,8 @@ func headlessPagesPublisher(s *Site, wg *sync.WaitGroup) {
 	defer wg.Done()
 	s.PageStore.eachPages(func(page *Page) (error) {
 		outFormat := page.outputFormats[0] // There is only one
-		if outFormat != s.rc.Format {
-			// Avoid double work.
-			return nil
-		}
+		page.rc = &siteRenderingContext{Format: outFormat}
+		page.setContentInit(true)
 		pageOutput, err := newPageOutput(page, false, outFormat)
 		if err == nil {
 			page.mainPageOutput = pageOutput
@@ -99,6 +97,18 @@ func pageRenderer(s *Site, pages <-chan *Page, results chan<- error, wg *sync.Wa
 
 	for page := range pages {
 
+		if page.thinAction == thinActionRedirect {
+			if err := s.renderThinRedirect(page); err != nil {
+				results <- err
+			}
+			continue
+		}
+
+		// All output formats are rendered from this one load of the page.
+		// The content is only re-rendered for a format when it has its own
+		// shortcode variants, so keep the unprocessed content around.
+		workContent := page.workContent
+
 		for i, outFormat := range page.outputFormats {
 
 			var (
@@ -106,16 +116,15 @@ func pageRenderer(s *Site, pages <-chan *Page, results chan<- error, wg *sync.Wa
 				err        error
 			)
 
+			page.rc = &siteRenderingContext{Format: outFormat}
+			page.workContent = workContent
+			page.setContentInit(i == 0)
+
 			if i == 0 {
 				pageOutput, err = newPageOutput(page, false, outFormat)
 				page.mainPageOutput = pageOutput
 			}
 
-			if outFormat != page.s.rc.Format {
-				// Will be rendered  ... later.
-				continue
-			}
-
 			if pageOutput == nil {
 				pageOutput, err = page.mainPageOutput.copyWithFormat(outFormat)
 			}
@@ -177,7 +186,7 @@ func pageRenderer(s *Site, pages <-chan *Page, results chan<- error, wg *sync.Wa
 					results <- err
 				}
 
-				if pageOutput.IsNode() {
+				if pageOutput.listsPages() {
 					if err := s.renderPaginator(pageOutput); err != nil {
 						results <- err
 					}
@@ -221,21 +230,11 @@ func (s *Site) renderPaginator(p *PageOutput) error {
 				continue
 			}
 
-			pagerNode, err := p.copy()
+			pagerNode, err := p.pagerCopy(pager)
 			if err != nil {
 				return err
 			}
 
-			pagerNode.origOnCopy = p.Page
-
-			pagerNode.paginator = pager
-			if pager.TotalPages() > 0 {
-				//TODO David pager node properties need to be set at the end
-				//first, _ := pager.page(0)
-				//pagerNode.Date = first.Date
-				//pagerNode.Lastmod = first.Lastmod
-			}
-
 			pageNumber := i + 1
 			addend := fmt.Sprintf("/%s/%d", paginatePath, pageNumber)
 			targetPath, _ := p.targetPath(addend)
@@ -252,11 +251,34 @@ func (s *Site) renderPaginator(p *PageOutput) error {
 				return err
 			}
 
+			// The pages of a store pager are read again if needed.
+			pager.release()
+
 		}
 	}
 	return nil
 }
 
+// pagerCopy returns the output of one of the pagers of p.
+func (p *PageOutput) pagerCopy(pager *Pager) (*PageOutput, error) {
+	pagerNode, err := p.copy()
+	if err != nil {
+		return nil, err
+	}
+
+	pagerNode.origOnCopy = p.Page
+
+	pagerNode.paginator = pager
+	if pager.TotalPages() > 0 {
+		//TODO David pager node properties need to be set at the end
+		//first, _ := pager.page(0)
+		//pagerNode.Date = first.Date
+		//pagerNode.Lastmod = first.Lastmod
+	}
+
+	return pagerNode, nil
+}
+
 // renderPaginator must be run after the owning Page has been rendered.
 func (s *Site) renderPaginatorXML(p *PageOutput, layouts ...string) error {
 	if p.paginator != nil {
@@ -318,6 +340,9 @@ func (s *Site) renderPaginatorXML(p *PageOutput, layouts ...string) error {
 				return err
 			}
 
+			// The pages of a store pager are read again if needed.
+			pager.release()
+
 		}
 	}
 	return nil
@@ -329,17 +354,7 @@ func (s *Site) renderRSS(p *PageOutput) error {
 		return nil
 	}
 
-	p.Kind = kindRSS
-
-	limit := s.Cfg.GetInt("rssLimit")
-	if limit >= 0 && len(p.Pages) > limit {
-		p.Pages = p.Pages[:limit]
-		p.Data["Pages"] = p.Pages
-	}
-
-	layouts, err := s.layoutHandler.For(
-		p.layoutDescriptor,
-		p.outputFormat)
+	layouts, err := s.rssLayouts(p)
 	if err != nil {
 		return err
 	}
@@ -353,6 +368,25 @@ func (s *Site) renderRSS(p *PageOutput) error {
 		targetPath, p, layouts...)
 }
 
+// rssLayouts readies p to be rendered as a feed and returns its layouts.
+func (s *Site) rssLayouts(p *PageOutput) ([]string, error) {
+	p.Kind = kindRSS
+
+	limit := s.Cfg.GetInt("rssLimit")
+	if sp, ok := p.Data["Pages"].(StorePages); ok {
+		if limit >= 0 {
+			p.Data["Pages"] = sp.Limit(limit)
+		}
+	} else if limit >= 0 && len(p.Pages) > limit {
+		p.Pages = p.Pages[:limit]
+		p.Data["Pages"] = p.Pages
+	}
+
+	return s.layoutHandler.For(
+		p.layoutDescriptor,
+		p.outputFormat)
+}
+
 func (s *Site) render404() error {
 	if !s.isEnabled(kind404) {
 		return nil
@@ -361,8 +395,7 @@ func (s *Site) render404() error {
 	p := s.newNodePage(kind404)
 
 	p.title = "404 Page not found"
-	//p.Data["Pages"] = s.Pages
-	//p.Pages = s.Pages
+	p.Data["Pages"] = s.Info.Pages()
 	p.URLPath.URL = "404.html"
 
 	if err := p.initTargetPathDescriptor(); err != nil {
@@ -398,8 +431,9 @@ func (s *Site) renderSitemap() error {
 	n := s.newNodePage(kindSitemap)
 	outputFormat, _ := newPageOutput(n, false, n.outputFormats[0])
 	n.mainPageOutput = outputFormat
-	// Include all pages (regular, home page, taxonomies etc.)
-	//pages := s.Pages
+	// Include all pages (regular, home page, taxonomies etc.), but not the
+	// thin or non-canonical ones, see ThinPagesRule and CanonicalConfig.
+	pages := s.Info.indexedPages()
 
 	page := s.newNodePage(kindSitemap)
 	page.URLPath.URL = ""
@@ -410,8 +444,7 @@ func (s *Site) renderSitemap() error {
 	page.Sitemap.Priority = sitemapDefault.Priority
 	page.Sitemap.Filename = sitemapDefault.Filename
 
-	//n.Data["Pages"] = pages
-	//n.Pages = pages
+	n.Data["Pages"] = pages
 
 	// TODO(bep) we have several of these
 	if err := page.initTargetPathDescriptor(); err != nil {
@@ -420,16 +453,16 @@ func (s *Site) renderSitemap() error {
 
 	// TODO(bep) this should be done somewhere else
 	s.PageStore.eachPages(func(p *Page) (error) {
-		if page.Sitemap.ChangeFreq == "" {
-			page.Sitemap.ChangeFreq = sitemapDefault.ChangeFreq
+		if p.Sitemap.ChangeFreq == "" {
+			p.Sitemap.ChangeFreq = sitemapDefault.ChangeFreq
 		}
 
-		if page.Sitemap.Priority == -1 {
-			page.Sitemap.Priority = sitemapDefault.Priority
+		if p.Sitemap.Priority == -1 {
+			p.Sitemap.Priority = sitemapDefault.Priority
 		}
 
-		if page.Sitemap.Filename == "" {
-			page.Sitemap.Filename = sitemapDefault.Filename
+		if p.Sitemap.Filename == "" {
+			p.Sitemap.Filename = sitemapDefault.Filename
 		}
 
 		return nil
@@ -464,8 +497,7 @@ func (s *Site) renderRobotsTXT() error {
 	if err := p.initTargetPathDescriptor(); err != nil {
 		return err
 	}
-	//p.Data["Pages"] = s.Pages
-	//p.Pages = s.Pages
+	p.Data["Pages"] = s.Info.Pages()
 
 	rLayouts := []string{"robots.txt", "_default/robots.txt", "_internal/_default/robots.txt"}
 
@@ -483,6 +515,24 @@ func (s *Site) renderRobotsTXT() error {
 
 }
 
+// aliasPath returns where the alias a of the output format f of p is
+// published.
+func (s *Site) aliasPath(p *Page, f output.Format, a string) string {
+	if f.Path != "" {
+		// Make sure AMP and similar doesn't clash with regular aliases.
+		a = path.Join(a, f.Path)
+	}
+
+	lang := p.Lang()
+
+	if s.owner.multihost && !strings.HasPrefix(a, "/"+lang) {
+		// These need to be in its language root.
+		a = path.Join(lang, a)
+	}
+
+	return a
+}
+
 // renderAliases renders shell pages that simply have a redirect in the header.
 func (s *Site) renderAliases() error {
 	s.PageStore.eachPages(func(p *Page) (error) {
@@ -499,19 +549,7 @@ func (s *Site) renderAliases() error {
 			plink := o.Permalink()
 
 			for _, a := range p.Aliases {
-				if f.Path != "" {
-					// Make sure AMP and similar doesn't clash with regular aliases.
-					a = path.Join(a, f.Path)
-				}
-
-				lang := p.Lang()
-
-				if s.owner.multihost && !strings.HasPrefix(a, "/"+lang) {
-					// These need to be in its language root.
-					a = path.Join(lang, a)
-				}
-
-				if err := s.writeDestAlias(a, plink, p); err != nil {
+				if err := s.writeDestAlias(s.aliasPath(p, f, a), plink, p); err != nil {
 					return err
 				}
 			}
@@ -16,6 +16,7 @@ package hugolib
 import (
 	"fmt"
 	"sort"
+	"time"
 )
 
 // The TaxonomyList is a list of all taxonomies and their values
@@ -48,10 +49,11 @@ type WeightedPageIds struct {
 	PageId      PageId
 	Key         string
 	Plural      string
+	Lang        string
+	Section     string
+	Date        time.Time
+	Title       string
 	//Params      map[string]interface{}
-	//SearchKeys  []string
-	//Cardinality int
-	//SearchLabel string
 }
 
 func (w WeightedPage) String() string {
@@ -67,6 +69,10 @@ type OrderedTaxonomy []OrderedTaxonomyEntry
 type OrderedTaxonomyEntry struct {
 	Name          string
 	WeightedPages WeightedPages
+
+	// Set for entries of a StoreTaxonomy, whose pages are not loaded
+	// up front.
+	term *TaxonomyTerm
 }
 
 // Get the weighted pages for the given key.
@@ -124,11 +130,17 @@ func (i Taxonomy) ByCount() OrderedTaxonomy {
 
 // Pages returns the Pages for this taxonomy.
 func (ie OrderedTaxonomyEntry) Pages() Pages {
+	if ie.WeightedPages == nil && ie.term != nil {
+		return ie.term.Pages()
+	}
 	return ie.WeightedPages.Pages()
 }
 
 // Count returns the count the pages in this taxonomy.
 func (ie OrderedTaxonomyEntry) Count() int {
+	if ie.WeightedPages == nil && ie.term != nil {
+		return ie.term.Count
+	}
 	return len(ie.WeightedPages)
 }
 
@@ -0,0 +1,229 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// weightedPagesSort is the order of the pages of a taxonomy term: by
+// <plural>_weight, then newest first, then by title. This mirrors
+// WeightedPages.Sort.
+var weightedPagesSort = []string{"weight", "-date", "title"}
+
+// A TaxonomyTerm is one term of a taxonomy with the number of pages in it,
+// as materialized in the taxonomy_terms collection once per build.
+type TaxonomyTerm struct {
+	ID     string `bson:"_id"`
+	Plural string
+	Key    string
+	Count  int
+
+	// The lowest <plural>_weight of the pages in this term.
+	Weight int
+
+	ps *PageStore
+}
+
+// Name returns the term, e.g. "lenovo".
+func (t *TaxonomyTerm) Name() string {
+	return t.Key
+}
+
+// WeightedPages loads the pages of this term from the store, ordered by
+// <plural>_weight.
+func (t *TaxonomyTerm) WeightedPages() WeightedPages {
+	return t.ps.getWeightedPagesByTaxonomyKey(t.Plural, t.Key)
+}
+
+// Pages returns the pages of this term, ordered by <plural>_weight.
+func (t *TaxonomyTerm) Pages() Pages {
+	return t.WeightedPages().Pages()
+}
+
+// PageIds returns the ids of the pages of this term, ordered by
+// <plural>_weight.
+func (t *TaxonomyTerm) PageIds() PageIds {
+	return t.ps.getPageIdsByTaxonomyKey(t.Plural, t.Key)
+}
+
+func (t *TaxonomyTerm) String() string {
+	return fmt.Sprintf("TaxonomyTerm(%s,%q,%d)", t.Plural, t.Key, t.Count)
+}
+
+// StoreTaxonomyList is what templates see as .Site.Taxonomies.
+type StoreTaxonomyList map[string]StoreTaxonomy
+
+func (tl StoreTaxonomyList) String() string {
+	return fmt.Sprintf("TaxonomyList(%d)", len(tl))
+}
+
+// A StoreTaxonomy is a Taxonomy backed by the page store. It holds the
+// terms with their counts; the pages are loaded when asked for.
+type StoreTaxonomy map[string]*TaxonomyTerm
+
+// Get the weighted pages for the given key.
+func (i StoreTaxonomy) Get(key string) WeightedPages {
+	t, found := i[key]
+	if !found {
+		return nil
+	}
+	return t.WeightedPages()
+}
+
+// Count the weighted pages for the given key.
+func (i StoreTaxonomy) Count(key string) int {
+	t, found := i[key]
+	if !found {
+		return 0
+	}
+	return t.Count
+}
+
+// TaxonomyArray returns an ordered taxonomy with a non defined order.
+func (i StoreTaxonomy) TaxonomyArray() OrderedTaxonomy {
+	ies := make([]OrderedTaxonomyEntry, len(i))
+	count := 0
+	for k, v := range i {
+		ies[count] = OrderedTaxonomyEntry{Name: k, term: v}
+		count++
+	}
+	return ies
+}
+
+// Alphabetical returns an ordered taxonomy sorted by key name.
+func (i StoreTaxonomy) Alphabetical() OrderedTaxonomy {
+	name := func(i1, i2 *OrderedTaxonomyEntry) bool {
+		return i1.Name < i2.Name
+	}
+
+	ia := i.TaxonomyArray()
+	oiBy(name).Sort(ia)
+	return ia
+}
+
+// ByCount returns an ordered taxonomy sorted by # of pages per key.
+// If taxonomies have the same # of pages, sort them alphabetical
+func (i StoreTaxonomy) ByCount() OrderedTaxonomy {
+	count := func(i1, i2 *OrderedTaxonomyEntry) bool {
+		li1 := i1.Count()
+		li2 := i2.Count()
+
+		if li1 == li2 {
+			return i1.Name < i2.Name
+		}
+		return li1 > li2
+	}
+
+	ia := i.TaxonomyArray()
+	oiBy(count).Sort(ia)
+	return ia
+}
+
+// materializeTaxonomyTerms counts the pages per taxonomy term in
+// weighted_pages into the taxonomy_terms collection. It runs once per build,
+// after the taxonomies are assembled, so that term listings do not need to
+// aggregate weighted_pages again.
+func (ps *PageStore) materializeTaxonomyTerms() {
+	pipe := []bson.M{
+		{"$group": bson.M{
+			"_id":    bson.M{"plural": "$plural", "key": "$key"},
+			"count":  bson.M{"$sum": 1},
+			"weight": bson.M{"$min": "$weight"},
+		}},
+		{"$project": bson.M{
+			"_id":    bson.M{"$concat": []string{"$_id.plural", "_", "$_id.key"}},
+			"plural": "$_id.plural",
+			"key":    "$_id.key",
+			"count":  1,
+			"weight": 1,
+		}},
+		{"$out": "taxonomy_terms"},
+	}
+
+	items := ps.MongoSession.DB("hugo").C("weighted_pages").Pipe(pipe).AllowDiskUse().Iter()
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	index := mgo.Index{
+		Key:        []string{"plural", "-count", "key"},
+		Unique:     false,
+		DropDups:   false,
+		Background: true,
+		Sparse:     false,
+	}
+
+	if err := ps.MongoSession.DB("hugo").C("taxonomy_terms").EnsureIndex(index); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+}
+
+// loadTaxonomy reads the materialized terms of the given taxonomy.
+func (ps *PageStore) loadTaxonomy(plural string) StoreTaxonomy {
+	taxonomy := make(StoreTaxonomy)
+
+	items := ps.MongoSession.DB("hugo").C("taxonomy_terms").Find(bson.M{"plural": plural}).Batch(1000).Iter()
+
+	item := TaxonomyTerm{}
+	for items.Next(&item) {
+		t := item
+		t.ps = ps
+		taxonomy[t.Key] = &t
+	}
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return taxonomy
+}
+
+func (ps *PageStore) getWeightedPagesByTaxonomyKey(plural string, term string) WeightedPages {
+	var items []WeightedPageIds
+
+	err := ps.MongoSession.DB("hugo").C("weighted_pages").Find(bson.M{"plural": plural, "key": term}).Sort(weightedPagesSort...).All(&items)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	pageIds := make(PageIds, len(items))
+	for i, item := range items {
+		pageIds[i] = item.PageId
+	}
+
+	// getPagesById does not keep the order of pageIds.
+	pages := make(map[PageId]*Page)
+	for _, p := range ps.getPagesById(pageIds) {
+		pages[PageId(p.ID)] = p
+	}
+
+	weightedPages := make(WeightedPages, 0, len(items))
+	for _, item := range items {
+		if p, found := pages[item.PageId]; found {
+			weightedPages = append(weightedPages, WeightedPage{Weight: item.Weight, Page: p})
+		}
+	}
+
+	return weightedPages
+}
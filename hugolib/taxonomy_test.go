@@ -41,7 +41,7 @@ func TestByCountOrderOfTaxonomies(t *testing.T) {
 	s := buildSingleSite(t, deps.DepsCfg{Fs: fs, Cfg: cfg}, BuildCfg{})
 
 	st := make([]string, 0)
-	for _, t := range s.Taxonomies["tags"].ByCount() {
+	for _, t := range s.Info.Taxonomies["tags"].ByCount() {
 		st = append(st, t.Name)
 	}
 
@@ -211,3 +211,47 @@ permalinkeds:
 	th.assertFileContent(pathFunc("public/empties/index.html"), "Terms List", "Empties")
 
 }
+
+func TestStoreTaxonomyOrder(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	taxonomy := StoreTaxonomy{
+		"lenovo": &TaxonomyTerm{Plural: "brands", Key: "lenovo", Count: 3},
+		"dell":   &TaxonomyTerm{Plural: "brands", Key: "dell", Count: 5},
+		"acer":   &TaxonomyTerm{Plural: "brands", Key: "acer", Count: 3},
+	}
+
+	names := func(ot OrderedTaxonomy) []string {
+		var st []string
+		for _, e := range ot {
+			st = append(st, e.Term())
+		}
+		return st
+	}
+
+	assert.Equal([]string{"dell", "acer", "lenovo"}, names(taxonomy.ByCount()))
+	assert.Equal([]string{"acer", "dell", "lenovo"}, names(taxonomy.Alphabetical()))
+	assert.Equal(5, taxonomy.ByCount()[0].Count())
+	assert.Equal(3, taxonomy.Count("lenovo"))
+	assert.Equal(0, taxonomy.Count("hp"))
+	assert.Nil(taxonomy.Get("hp"))
+}
+
+func TestTaxonomyTermsFromStore(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", facetTestConfig)
+	b.WithContent(facetTestContent...)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `{{ .Title }}`,
+		"_default/terms.html", `{{ range .Data.Terms.ByCount }}{{ .Term }}={{ .Count }}|{{ end }}{{ len .Data.Index }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/brands/index.html", "acme=2|zeta=1|2")
+	b.AssertFileContent("public/colors/index.html", "red=2|blue=1|2")
+}
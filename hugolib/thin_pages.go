@@ -0,0 +1,287 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+
+	"github.com/gohugoio/hugo/helpers"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+)
+
+// What is done with a generated list page with too few pages in it.
+const (
+	// The page is not created.
+	thinActionSkip = "skip"
+
+	// The page is rendered, but marked noindex and left out of the sitemap.
+	thinActionNoIndex = "noindex"
+
+	// A redirect to the parent listing is rendered in place of the page.
+	thinActionRedirect = "redirect"
+)
+
+// ThinPagesRule is what to do with the generated list pages of a taxonomy,
+// or the facet combination pages of a section, with fewer than MinPages
+// pages, e.g.
+//
+//	[[thinPages]]
+//	taxonomy = "brands"
+//	minPages = 3
+//	action = "noindex"
+//
+//	[[thinPages]]
+//	section = "laptops"
+//	minPages = 5
+//	action = "redirect"
+//
+// A rule with neither a taxonomy nor a section applies to all generated
+// list pages without a rule of their own.
+type ThinPagesRule struct {
+	Taxonomy string
+	Section  string
+	MinPages int
+
+	// "skip", "noindex" (the default) or "redirect".
+	Action string
+}
+
+// ThinPagesConfig is the thin page rules of a site.
+type ThinPagesConfig []ThinPagesRule
+
+// decodeThinPagesConfig reads the thinPages config. Taxonomies are given as
+// plural by singular.
+func decodeThinPagesConfig(in interface{}, taxonomies map[string]string) (ThinPagesConfig, error) {
+	if in == nil {
+		return nil, nil
+	}
+
+	items, err := cast.ToSliceE(in)
+	if err != nil {
+		return nil, fmt.Errorf("thinPages must be a list: %s", err)
+	}
+
+	plurals := make(map[string]bool)
+	for _, plural := range taxonomies {
+		plurals[plural] = true
+	}
+
+	var (
+		rules ThinPagesConfig
+		seen  = make(map[ThinPagesRule]bool)
+	)
+
+	for _, item := range items {
+		var r ThinPagesRule
+		if err := mapstructure.WeakDecode(cast.ToStringMap(item), &r); err != nil {
+			return nil, fmt.Errorf("failed to decode thinPages rule: %s", err)
+		}
+
+		if r.Taxonomy != "" && r.Section != "" {
+			return nil, fmt.Errorf("thinPages rule can have a taxonomy or a section, not both")
+		}
+
+		if r.Taxonomy != "" && !plurals[r.Taxonomy] {
+			return nil, fmt.Errorf("thinPages taxonomy %q is not a configured taxonomy", r.Taxonomy)
+		}
+
+		if r.MinPages < 1 {
+			return nil, fmt.Errorf("thinPages minPages must be at least 1, got %d", r.MinPages)
+		}
+
+		switch r.Action {
+		case "":
+			r.Action = thinActionNoIndex
+		case thinActionSkip, thinActionNoIndex, thinActionRedirect:
+		default:
+			return nil, fmt.Errorf("thinPages action must be %q, %q or %q, got %q", thinActionSkip, thinActionNoIndex, thinActionRedirect, r.Action)
+		}
+
+		key := ThinPagesRule{Taxonomy: r.Taxonomy, Section: r.Section}
+		if seen[key] {
+			return nil, fmt.Errorf("more than one thinPages rule for taxonomy %q and section %q", r.Taxonomy, r.Section)
+		}
+		seen[key] = true
+
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+// rule returns the rule for the list pages of a taxonomy term, when
+// section is empty, or the facet combination pages of a section, falling
+// back to the default rule.
+func (c ThinPagesConfig) rule(taxonomy, section string) (ThinPagesRule, bool) {
+	var (
+		fallback ThinPagesRule
+		found    bool
+	)
+
+	for _, r := range c {
+		switch {
+		case r.Taxonomy == "" && r.Section == "":
+			fallback, found = r, true
+		case section == "" && r.Taxonomy == taxonomy:
+			return r, true
+		case section != "" && r.Section == section:
+			return r, true
+		}
+	}
+
+	return fallback, found
+}
+
+// action returns what to do with a generated list page with count pages,
+// or "" if it is not thin.
+func (c ThinPagesConfig) action(taxonomy, section string, count int) string {
+	r, found := c.rule(taxonomy, section)
+	if !found || count >= r.MinPages {
+		return ""
+	}
+	return r.Action
+}
+
+// ThinAction returns "noindex" or "redirect" if this is a generated list
+// page with too few pages in it, see ThinPagesRule, or "" if it is not.
+//
+//	{{ if .NoIndex }}<meta name="robots" content="noindex">{{ end }}
+func (p *Page) ThinAction() string {
+	return p.thinAction
+}
+
+// NoIndex is whether search engines should leave this page out.
+func (p *Page) NoIndex() bool {
+	return p.thinAction != ""
+}
+
+// indexedPages returns the pages in the current language that are neither
+// thin nor point to another canonical URL.
+func (siteInfo *SiteInfo) indexedPages() StorePages {
+	s := siteInfo.s
+
+	query := bson.M{
+		"lang":         s.Language.Lang,
+		"thinaction":   bson.M{"$exists": false},
+		"canonicalurl": bson.M{"$exists": false},
+	}
+
+	if s.canonical.Variants && s.variants.enabled() {
+		query["$nor"] = []bson.M{s.variants.nonMasterQuery()}
+	}
+
+	return s.PageStore.storePages(query)
+}
+
+// thinRedirectURL returns the relative URL of the parent listing of a thin
+// page: the term list of a taxonomy term, or the facet combination one
+// term less refined.
+func (s *Site) thinRedirectURL(p *Page) (string, bool) {
+	if c := p.facetCombination; c != nil {
+		if len(c.Terms) == 0 {
+			return "", false
+		}
+		parent := c.without(c.Terms[len(c.Terms)-1])
+		if u, ok := s.facetCombinationURL(parent); ok {
+			return u, true
+		}
+		return s.facetCombinationURL(FacetCombination{Section: c.Section})
+	}
+
+	if p.Kind != KindTaxonomy || len(p.sections) == 0 {
+		return "", false
+	}
+
+	return s.PathSpec.RelURL(helpers.SanitizeURLKeepTrailingSlash(s.PathSpec.URLize("/"+p.sections[0]+"/")), true), true
+}
+
+// renderThinRedirect writes a redirect to the parent listing in place of
+// the thin page p.
+func (s *Site) renderThinRedirect(p *Page) error {
+	target, ok := s.thinRedirectURL(p)
+	if !ok {
+		return fmt.Errorf("no parent listing to redirect %q to", p.pathOrTitle())
+	}
+
+	for _, f := range p.outputFormats {
+		if !f.IsHTML {
+			continue
+		}
+
+		pageOutput, err := newPageOutput(p, false, f)
+		if err != nil {
+			return err
+		}
+
+		targetPath, err := pageOutput.targetPath()
+		if err != nil {
+			return err
+		}
+
+		if err := s.writeDestAlias(targetPath, target, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dropSkippedTerms removes the terms of the taxonomy that got no page
+// because they are thin, see ThinPagesRule, from .Site.Taxonomies, so that
+// templates do not link to them. Terms with a page of their own are kept.
+func (s *Site) dropSkippedTerms(plural string) {
+	taxonomy := s.Info.Taxonomies[plural]
+
+	var withPage map[string]bool
+
+	for key, t := range taxonomy {
+		if s.thinPages.action(plural, "", t.Count) != thinActionSkip {
+			continue
+		}
+
+		if withPage == nil {
+			withPage = s.PageStore.taxonomyPageKeys(s.Language.Lang, plural)
+		}
+
+		if !withPage[s.PathSpec.MakePathSanitized(key)] {
+			delete(taxonomy, key)
+		}
+	}
+}
+
+// taxonomyPageKeys returns the terms of the taxonomy that have a page in
+// the store, as path elements.
+func (ps *PageStore) taxonomyPageKeys(lang, plural string) map[string]bool {
+	keys := make(map[string]bool)
+
+	items := ps.MongoSession.DB("hugo").C("pages").Find(bson.M{"lang": lang, "kind": KindTaxonomy, "sections.0": plural}).Select(bson.M{"sections": 1}).Batch(1000).Iter()
+
+	item := PageModel{}
+	for items.Next(&item) {
+		if len(item.Sections) > 1 {
+			keys[ps.Site.PathSpec.MakePathSanitized(item.Sections[1])] = true
+		}
+		item = PageModel{}
+	}
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return keys
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeThinPagesConfig(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	taxonomies := map[string]string{"brand": "brands", "color": "colors"}
+
+	c, err := decodeThinPagesConfig(nil, taxonomies)
+	assert.NoError(err)
+	assert.Nil(c)
+	assert.Equal("", c.action("brands", "", 0))
+
+	c, err = decodeThinPagesConfig([]map[string]interface{}{
+		{"taxonomy": "brands", "minPages": "3", "action": "skip"},
+		{"section": "laptops", "minPages": 5, "action": "redirect"},
+		{"minPages": 2},
+	}, taxonomies)
+	assert.NoError(err)
+	assert.Len(c, 3)
+	assert.Equal(thinActionNoIndex, c[2].Action)
+
+	assert.Equal(thinActionSkip, c.action("brands", "", 2))
+	assert.Equal("", c.action("brands", "", 3))
+	assert.Equal(thinActionNoIndex, c.action("colors", "", 1))
+	assert.Equal("", c.action("colors", "", 2))
+	assert.Equal(thinActionRedirect, c.action("", "laptops", 4))
+	assert.Equal(thinActionNoIndex, c.action("", "phones", 1))
+
+	for _, in := range []interface{}{
+		"brands",
+		[]map[string]interface{}{{"taxonomy": "sizes", "minPages": 2}},
+		[]map[string]interface{}{{"taxonomy": "brands", "section": "laptops", "minPages": 2}},
+		[]map[string]interface{}{{"taxonomy": "brands"}},
+		[]map[string]interface{}{{"taxonomy": "brands", "minPages": 2, "action": "hide"}},
+		[]map[string]interface{}{{"section": "laptops", "minPages": 2}, {"section": "laptops", "minPages": 3}},
+	} {
+		_, err := decodeThinPagesConfig(in, taxonomies)
+		assert.Error(err, "%v", in)
+	}
+}
+
+func TestThinPagesBuild(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", facetTestConfig+`
+[[thinPages]]
+taxonomy = "brands"
+minPages = 2
+
+[[thinPages]]
+taxonomy = "colors"
+minPages = 2
+action = "redirect"
+`)
+	b.WithContent(facetTestContent...)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `{{ .Title }}|NoIndex: {{ .NoIndex }}|`,
+		"sitemap.xml", `{{ range .Data.Pages }}{{ .RelPermalink }} {{ end }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/brands/acme/index.html", "NoIndex: false|")
+	b.AssertFileContent("public/brands/zeta/index.html", "NoIndex: true|")
+	b.AssertFileContent("public/colors/red/index.html", "NoIndex: false|")
+	b.AssertFileContent("public/colors/blue/index.html", "url=/colors/")
+
+	sitemap := readDestination(t, b.Fs, "public/sitemap.xml")
+	assert.Contains(sitemap, "/brands/acme/ ")
+	assert.NotContains(sitemap, "/brands/zeta/ ")
+	assert.NotContains(sitemap, "/colors/blue/ ")
+}
+
+func TestThinPagesSkippedBuild(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", facetTestConfig+`
+[[thinPages]]
+taxonomy = "brands"
+minPages = 2
+action = "skip"
+`)
+	b.WithContent(facetTestContent...)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}`,
+		"_default/list.html", `{{ .Title }}|`,
+		"_default/terms.html", `{{ range .Data.Terms.ByCount }}{{ .Term }} {{ end }}|`,
+		"index.html", `{{ range $key, $_ := .Site.Taxonomies.brands }}{{ $key }} {{ end }}|`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	assert.False(b.CheckExists("public/brands/zeta/index.html"))
+
+	// The skipped term is not listed either.
+	b.AssertFileContent("public/index.html", "acme |")
+	b.AssertFileContent("public/brands/index.html", "acme |")
+}
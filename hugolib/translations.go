@@ -13,6 +13,13 @@
 
 package hugolib
 
+import (
+	"fmt"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
 // Translations represent the other translations for a given page. The
 // string here is the language code, as affected by the `post.LANG.md`
 // filename.
@@ -57,3 +64,104 @@ func assignTranslationsToPages(allTranslations map[string]Translations, pages []
 		pageBy(languagePageSort).Sort(page.translations)
 	}
 }
+
+// materializeTranslations groups the pages of all the language sites by
+// their TranslationKey into the translations collection, keyed by it. Only
+// keys with more than one page are kept. It runs once per build, after the
+// pages of all the sites are assembled.
+func (ps *PageStore) materializeTranslations() {
+	pipe := []bson.M{
+		{"$match": bson.M{"translationgroup": bson.M{"$nin": []interface{}{nil, ""}}}},
+		{"$group": bson.M{
+			"_id":     "$translationgroup",
+			"pageids": bson.M{"$push": "$_id"},
+			"count":   bson.M{"$sum": 1},
+		}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+		{"$project": bson.M{"pageids": 1}},
+		{"$out": "translations"},
+	}
+
+	items := ps.MongoSession.DB("hugo").C("pages").Pipe(pipe).AllowDiskUse().Iter()
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+}
+
+// translationIds returns the ids of the pages with the TranslationKey of
+// p, p included, and nil if it has no translations.
+func (ps *PageStore) translationIds(p *Page) PageIds {
+	var item struct {
+		PageIds PageIds `bson:"pageids"`
+	}
+
+	err := ps.MongoSession.DB("hugo").C("translations").FindId(p.TranslationKey()).One(&item)
+
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return item.PageIds
+}
+
+// refreshTranslations groups the pages with the given TranslationKey again
+// after an update, in the translations collection and in the translation
+// ids stored with each of them. It returns the ids, nil if there is only
+// one page left with the key.
+func (ps *PageStore) refreshTranslations(key string) PageIds {
+	if key == "" {
+		return nil
+	}
+
+	ids := ps.getPageIds(bson.M{"translationgroup": key}, nil)
+
+	var err error
+	if len(ids) > 1 {
+		_, err = ps.MongoSession.DB("hugo").C("translations").UpsertId(key, bson.M{"$set": bson.M{"pageids": ids}})
+	} else {
+		ids = nil
+		if err = ps.MongoSession.DB("hugo").C("translations").RemoveId(key); err == mgo.ErrNotFound {
+			err = nil
+		}
+	}
+
+	if err == nil {
+		_, err = ps.MongoSession.DB("hugo").C("pages").UpdateAll(bson.M{"translationgroup": key}, bson.M{"$set": bson.M{"translationsids": ids}})
+	}
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	return ids
+}
+
+// initTranslations reads the translations of p from the store, by the ids
+// set when the pages were prepared.
+func (p *Page) initTranslations() {
+	p.translationsInit.Do(func() {
+		if len(p.translations) > 0 || len(p.translationsIds) == 0 || p.s == nil {
+			return
+		}
+
+		translations := make(Pages, 0, len(p.translationsIds))
+
+		for _, t := range p.s.PageStore.getPagesById(p.translationsIds) {
+			if t.ID == p.ID {
+				t = p
+			}
+			translations = append(translations, t)
+		}
+
+		pageBy(languagePageSort).Sort(translations)
+		p.translations = translations
+	})
+}
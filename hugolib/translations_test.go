@@ -0,0 +1,105 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/gohugoio/hugo/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageTranslationsNotInStore(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	newTranslatedPage := func(id, lang string) *Page {
+		return &Page{ID: id, language: &helpers.Language{Lang: lang}, pageInit: &pageInit{}}
+	}
+
+	en, he := newTranslatedPage("en_1", "en"), newTranslatedPage("he_1", "he")
+	en.translations = Pages{en, he}
+
+	assert.True(en.IsTranslated())
+	assert.Equal(Pages{he}, en.Translations())
+	assert.Len(en.AllTranslations(), 2)
+
+	// Pages prepared without translation ids are not read from the store.
+	assert.False(he.IsTranslated())
+	assert.Empty(he.Translations())
+}
+
+func TestTranslationsFromStore(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "hugo-translations")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "produits.jsonl")
+	assert.NoError(ioutil.WriteFile(source, []byte(`{"sku": "hat", "title": "Chapeau"}`+"\n"), 0644))
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", fmt.Sprintf(`
+baseURL = "http://example.com/"
+defaultContentLanguage = "en"
+
+[languages]
+[languages.en]
+weight = 1
+[languages.fr]
+weight = 2
+
+[[ingest]]
+name = "produits"
+source = %q
+section = "products"
+slug = "{{ .sku }}"
+lang = "fr"
+`, source))
+	b.WithContent(
+		"products/pants.md", "---\ntitle: Pants\ntranslationKey: pants\n---\n",
+		"products/pants.fr.md", "---\ntitle: Pantalon\ntranslationKey: pants\n---\n",
+		"products/shirt.md", "---\ntitle: Shirt\ntranslationKey: shirt\n---\n",
+	)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}|Translations: {{ range .Translations }}{{ .Lang }}:{{ .Title }} {{ end }}|`,
+		"_default/list.html", `{{ .Title }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/pants/index.html", "Pants|Translations: fr:Pantalon |")
+	b.AssertFileContent("public/fr/products/pants/index.html", "Pantalon|Translations: en:Pants |")
+	b.AssertFileContent("public/products/shirt/index.html", "Shirt|Translations: |")
+
+	// A pushed French shirt is linked to the English one in the store.
+	_, err = b.H.Update("produits", []map[string]interface{}{
+		{"sku": "shirt", "title": "Chemise", "translationKey": "shirt"},
+	})
+	assert.NoError(err)
+
+	b.AssertFileContent("public/fr/products/shirt/index.html", "Chemise|Translations: en:Shirt |")
+
+	ps := b.H.Sites[0].PageStore
+	ids := ps.getPageIds(bson.M{"lang": "en", "title": "Shirt"}, nil)
+	assert.Len(ids, 1)
+	assert.Len(ps.getPageById(ids[0]).translationsIds, 2)
+}
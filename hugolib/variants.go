@@ -0,0 +1,288 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+)
+
+// How many variant masters are marked at a time.
+const variantMastersBatchSize = 1000
+
+// VariantsConfig configures how product variants are grouped, e.g.
+//
+//	[variants]
+//	groupParam = "variant_group"
+//	masterParam = "master_variation"
+//
+// Pages with the same value of the group param are variants (colours,
+// sizes etc.) of one product, one of which is marked as the master.
+type VariantsConfig struct {
+	// The param holding the variant group key. Variants are off without it.
+	GroupParam string
+
+	// The boolean param marking the master variant of a group. Defaults
+	// to "master_variation".
+	MasterParam string
+}
+
+func decodeVariantsConfig(in interface{}) (VariantsConfig, error) {
+	c := VariantsConfig{MasterParam: "master_variation"}
+
+	if in == nil {
+		return c, nil
+	}
+
+	if err := mapstructure.WeakDecode(cast.ToStringMap(in), &c); err != nil {
+		return c, fmt.Errorf("failed to decode variants: %s", err)
+	}
+
+	c.GroupParam = strings.ToLower(c.GroupParam)
+	c.MasterParam = strings.ToLower(c.MasterParam)
+
+	if c.GroupParam != "" && c.MasterParam == "" {
+		return c, fmt.Errorf("variants masterParam must not be empty")
+	}
+
+	return c, nil
+}
+
+func (c VariantsConfig) enabled() bool {
+	return c.GroupParam != ""
+}
+
+func (c VariantsConfig) groupField() string {
+	return "params." + c.GroupParam
+}
+
+func (c VariantsConfig) masterField() string {
+	return "params." + c.MasterParam
+}
+
+// variantsQuery matches the pages that are variants.
+func (c VariantsConfig) variantsQuery() bson.M {
+	return bson.M{c.groupField(): bson.M{"$nin": []interface{}{nil, ""}}}
+}
+
+// collapsedQuery matches the pages that are not variants and the master
+// variants, see markVariantMasters.
+func (c VariantsConfig) collapsedQuery() bson.M {
+	return bson.M{"$or": []bson.M{
+		{c.groupField(): bson.M{"$in": []interface{}{nil, ""}}},
+		{"variantmaster": true},
+	}}
+}
+
+// nonMasterQuery matches the variants that are not the master.
+func (c VariantsConfig) nonMasterQuery() bson.M {
+	return bson.M{c.groupField(): bson.M{"$nin": []interface{}{nil, ""}}, "variantmaster": bson.M{"$ne": true}}
+}
+
+// variantGroup returns the variant group key of p, and false if p is not a
+// variant.
+func (p *Page) variantGroup() (interface{}, bool) {
+	c := p.s.variants
+	if !c.enabled() {
+		return nil, false
+	}
+
+	v := p.params[c.GroupParam]
+	if v == nil || v == "" {
+		return nil, false
+	}
+
+	return v, true
+}
+
+// IsMasterVariant is whether p is the master of its variants, as picked by
+// markVariantMasters. Pages that are not variants are their own master.
+func (p *Page) IsMasterVariant() bool {
+	if _, ok := p.variantGroup(); !ok {
+		return true
+	}
+	return p.variantMaster
+}
+
+// Variants returns the variants of the product p is a variant of, p
+// included, or nothing if p is not a variant.
+//
+//	{{ range .Variants }}<a href="{{ .Permalink }}">{{ .Params.color }}</a>{{ end }}
+func (p *Page) Variants() StorePages {
+	group, ok := p.variantGroup()
+	if !ok {
+		return p.s.PageStore.storePagesByIds(nil)
+	}
+
+	return p.s.PageStore.storePages(bson.M{"lang": p.Lang(), p.s.variants.groupField(): group})
+}
+
+// MasterVariant returns the master of the variants of p. It is p itself if
+// p is not a variant, and the first of the variants if none is marked as
+// the master.
+func (p *Page) MasterVariant() *Page {
+	group, ok := p.variantGroup()
+	if !ok || p.IsMasterVariant() {
+		return p
+	}
+
+	model := PageModel{}
+	err := p.s.PageStore.MongoSession.DB("hugo").C("pages").
+		Find(bson.M{"lang": p.Lang(), p.s.variants.groupField(): group, "variantmaster": true}).
+		One(&model)
+
+	if err == mgo.ErrNotFound {
+		if pages := p.Variants().Limit(1).Pages(); len(pages) > 0 {
+			return pages[0]
+		}
+		return p
+	}
+
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	master := p.s.PageStore.pageModelToPage(&model)
+
+	return &master
+}
+
+// markVariantMasters stores which variant is the master of each variant
+// group in the language, or of the given group only: the first of the
+// variants in the default sort marked with the master param, or the first
+// of them if none is. The master is stored as variantmaster, for
+// CollapseVariants and the sitemap to query.
+func (ps *PageStore) markVariantMasters(lang string, group interface{}) {
+	c := ps.Site.variants
+	if !c.enabled() {
+		return
+	}
+
+	pages := ps.MongoSession.DB("hugo").C("pages")
+
+	match := bson.M{"$and": []bson.M{{"lang": lang}, c.variantsQuery()}}
+	if group != nil {
+		match = bson.M{"lang": lang, c.groupField(): group}
+	}
+
+	if _, err := pages.UpdateAll(bson.M{"$and": []bson.M{match, {"variantmaster": true}}},
+		bson.M{"$unset": bson.M{"variantmaster": ""}}); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	pipe := []bson.M{
+		{"$match": match},
+		{"$addFields": bson.M{"variantmarked": bson.M{"$eq": []interface{}{"$" + c.masterField(), true}}}},
+		{"$sort": sortDocument(append([]string{"-variantmarked"}, defaultStorePagesSort...))},
+		{"$group": bson.M{"_id": "$" + c.groupField(), "master": bson.M{"$first": "$_id"}}},
+	}
+
+	items := pages.Pipe(pipe).AllowDiskUse().Batch(variantMastersBatchSize).Iter()
+
+	var (
+		bulk = pages.Bulk()
+		n    int
+		r    struct {
+			Master string `bson:"master"`
+		}
+	)
+
+	run := func() {
+		if _, err := bulk.Run(); err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+		bulk = pages.Bulk()
+		n = 0
+	}
+
+	for items.Next(&r) {
+		bulk.Update(bson.M{"_id": r.Master}, bson.M{"$set": bson.M{"variantmaster": true}})
+		n++
+		if n == variantMastersBatchSize {
+			run()
+		}
+	}
+
+	if err := items.Close(); err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+
+	if n > 0 {
+		run()
+	}
+}
+
+// isVariantMaster is whether the stored page with the given id was marked
+// as the master of its variants by markVariantMasters.
+func (ps *PageStore) isVariantMaster(id PageId) bool {
+	n, err := ps.MongoSession.DB("hugo").C("pages").Find(bson.M{"_id": id, "variantmaster": true}).Count()
+	if err != nil {
+		fmt.Println(err.Error())
+		panic(err)
+	}
+	return n > 0
+}
+
+// CollapseVariants leaves out the variants that are not the master, so
+// that a listing shows each product once.
+//
+//	{{ range (.Paginate .Data.Pages.CollapseVariants).Pages }}...{{ end }}
+func (sp StorePages) CollapseVariants() StorePages {
+	c := sp.ps.Site.variants
+	if !c.enabled() {
+		return sp
+	}
+
+	return sp.where(c.collapsedQuery())
+}
+
+// where narrows the collection down to the pages also matching cond. A
+// collection of ids keeps its order.
+func (sp StorePages) where(cond bson.M) StorePages {
+	sp = sp.fixed()
+
+	if sp.ids != nil {
+		matched := make(map[PageId]bool)
+		for _, id := range sp.ps.getPageIds(bson.M{"$and": []bson.M{sp.filter(), cond}}, nil) {
+			matched[id] = true
+		}
+
+		ids := make(PageIds, 0, len(matched))
+		for _, id := range sp.ids {
+			if matched[id] {
+				ids = append(ids, id)
+			}
+		}
+		sp.ids = ids
+
+		return sp
+	}
+
+	if len(sp.query) == 0 {
+		sp.query = cond
+	} else {
+		sp.query = bson.M{"$and": []bson.M{sp.query, cond}}
+	}
+
+	return sp
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugolib
+
+import (
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeVariantsConfig(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	c, err := decodeVariantsConfig(nil)
+	assert.NoError(err)
+	assert.False(c.enabled())
+
+	c, err = decodeVariantsConfig(map[string]interface{}{"groupParam": "Variant_Group"})
+	assert.NoError(err)
+	assert.True(c.enabled())
+	assert.Equal("params.variant_group", c.groupField())
+	assert.Equal("params.master_variation", c.masterField())
+
+	assert.Equal(bson.M{"$or": []bson.M{
+		{"params.variant_group": bson.M{"$in": []interface{}{nil, ""}}},
+		{"variantmaster": true},
+	}}, c.collapsedQuery())
+
+	assert.Equal(bson.M{
+		"params.variant_group": bson.M{"$nin": []interface{}{nil, ""}},
+		"variantmaster":        bson.M{"$ne": true},
+	}, c.nonMasterQuery())
+
+	_, err = decodeVariantsConfig(map[string]interface{}{"groupParam": "variant_group", "masterParam": ""})
+	assert.Error(err)
+}
+
+func TestStorePagesWhere(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	ps := &PageStore{}
+	cond := bson.M{"params.master_variation": true}
+
+	sp := ps.storePages(bson.M{}).where(cond)
+	assert.Equal(cond, sp.query)
+
+	sp = ps.storePages(bson.M{"kind": KindPage}).where(cond)
+	assert.Equal(bson.M{"$and": []bson.M{{"kind": KindPage}, cond}}, sp.query)
+}
+
+func TestCollapseVariants(t *testing.T) {
+	t.Parallel()
+
+	b := newTestSitesBuilder(t)
+	b.WithConfigFile("toml", `
+baseURL = "http://example.com/"
+
+[variants]
+groupParam = "variant_group"
+
+[canonical]
+variants = true
+`)
+	// The shirts have no master, so the first of them is. Both caps are
+	// marked, so the first of them is.
+	b.WithContent(
+		"products/blue.md", "---\ntitle: Blue\nweight: 1\nvariant_group: shirt\n---\n",
+		"products/small.md", "---\ntitle: Small\nweight: 2\nvariant_group: hat\n---\n",
+		"products/red.md", "---\ntitle: Red\nweight: 3\nvariant_group: shirt\n---\n",
+		"products/large.md", "---\ntitle: Large\nweight: 4\nvariant_group: hat\nmaster_variation: true\n---\n",
+		"products/plain.md", "---\ntitle: Plain\nweight: 5\n---\n",
+		"products/cap1.md", "---\ntitle: Cap1\nweight: 6\nvariant_group: cap\nmaster_variation: true\n---\n",
+		"products/cap2.md", "---\ntitle: Cap2\nweight: 7\nvariant_group: cap\nmaster_variation: true\n---\n",
+	)
+	b.WithTemplates(
+		"_default/single.html", `{{ .Title }}|{{ .Canonical }}|{{ .IsMasterVariant }}`,
+		"_default/list.html", `Collapsed: {{ range .Data.Pages.CollapseVariants.ByWeight }}{{ .Title }}|{{ end }}`,
+		"sitemap.xml", `Indexed: {{ range .Data.Pages.ByWeight }}{{ if eq .Kind "page" }}{{ .Title }}|{{ end }}{{ end }}`,
+	)
+
+	b.CreateSites().Build(BuildCfg{})
+
+	b.AssertFileContent("public/products/index.html", "Collapsed: Blue|Large|Plain|Cap1|")
+	b.AssertFileContent("public/sitemap.xml", "Indexed: Blue|Large|Plain|Cap1|")
+	b.AssertFileContent("public/products/red/index.html", "Red|http://example.com/products/blue/|false")
+	b.AssertFileContent("public/products/small/index.html", "Small|http://example.com/products/large/|false")
+	b.AssertFileContent("public/products/cap1/index.html", "Cap1|http://example.com/products/cap1/|true")
+	b.AssertFileContent("public/products/cap2/index.html", "Cap2|http://example.com/products/cap1/|false")
+}
@@ -60,6 +60,13 @@ func (ns *Namespace) After(index interface{}, seq interface{}) (interface{}, err
 		return nil, errors.New("can't return negative/empty count of items from sequence")
 	}
 
+	if l, ok := seq.(Lazy); ok {
+		if indexv >= l.Len() {
+			return nil, errors.New("no items left")
+		}
+		return l.Slice(indexv, l.Len()), nil
+	}
+
 	seqv := reflect.ValueOf(seq)
 	seqv, isNil := indirect(seqv)
 	if isNil {
@@ -217,6 +224,10 @@ func (ns *Namespace) First(limit interface{}, seq interface{}) (interface{}, err
 		return nil, errors.New("can't return negative/empty count of items from sequence")
 	}
 
+	if l, ok := seq.(Lazy); ok {
+		return l.Slice(0, limitv), nil
+	}
+
 	seqv := reflect.ValueOf(seq)
 	seqv, isNil := indirect(seqv)
 	if isNil {
@@ -347,6 +358,14 @@ func (ns *Namespace) Last(limit interface{}, seq interface{}) (interface{}, erro
 		return nil, errors.New("can't return negative/empty count of items from sequence")
 	}
 
+	if l, ok := seq.(Lazy); ok {
+		n := l.Len()
+		if limitv > n {
+			limitv = n
+		}
+		return l.Slice(n-limitv, n), nil
+	}
+
 	seqv := reflect.ValueOf(seq)
 	seqv, isNil := indirect(seqv)
 	if isNil {
@@ -92,6 +92,23 @@ func init() {
 			[][2]string{},
 		)
 
+		ns.AddMethodMapping(ctx.LazyRange,
+			[]string{"lazyRange"},
+			[][2]string{},
+		)
+
+		ns.AddMethodMapping(ctx.LazyIndexedRange,
+			[]string{"lazyIndexedRange"},
+			[][2]string{},
+		)
+
+		ns.AddMethodMapping(ctx.Len,
+			[]string{"len"},
+			[][2]string{
+				{`{{ len (slice "a" "b" "c") }}`, `3`},
+			},
+		)
+
 		ns.AddMethodMapping(ctx.Last,
 			[]string{"last"},
 			[][2]string{},
@@ -0,0 +1,144 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collections
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// lazyChunkSize is how many elements of a Lazy sequence are read at a time.
+const lazyChunkSize = 200
+
+// Lazy is implemented by sequences that are not held in memory, e.g. the
+// page collections read from the page store. Slice must return a slice or
+// an array. Iterate calls yield for the elements in order, until yield
+// returns false, and should read them in one pass, e.g. with a cursor.
+type Lazy interface {
+	Len() int
+	Slice(from, to int) interface{}
+	Iterate(yield func(elem interface{}) bool)
+}
+
+// Len returns the length of seq. It replaces the len builtin, so that it
+// works for Lazy sequences too.
+func (ns *Namespace) Len(seq interface{}) (int, error) {
+	if l, ok := seq.(Lazy); ok {
+		return l.Len(), nil
+	}
+
+	seqv, isNil := indirect(reflect.ValueOf(seq))
+	if !seqv.IsValid() {
+		return 0, errors.New("len of untyped nil")
+	}
+	if isNil {
+		return 0, errors.New("len of nil pointer")
+	}
+
+	switch seqv.Kind() {
+	case reflect.Array, reflect.Chan, reflect.Map, reflect.Slice, reflect.String:
+		return seqv.Len(), nil
+	}
+
+	return 0, fmt.Errorf("len of type %s", seqv.Type())
+}
+
+// LazyRange is added to the pipeline of range actions with no or one
+// variable by the template AST transformer. A Lazy sequence is ranged over
+// as an iterator, so only the element at hand is held in memory; anything
+// else is returned as is.
+func (ns *Namespace) LazyRange(seq interface{}) interface{} {
+	l, ok := seq.(Lazy)
+	if !ok {
+		return seq
+	}
+
+	return func(yield func(interface{}) bool) {
+		l.Iterate(yield)
+	}
+}
+
+// LazyIndexedRange is LazyRange for range actions that declare both an
+// index and an element variable.
+func (ns *Namespace) LazyIndexedRange(seq interface{}) interface{} {
+	l, ok := seq.(Lazy)
+	if !ok {
+		return seq
+	}
+
+	return func(yield func(int, interface{}) bool) {
+		i := 0
+		l.Iterate(func(elem interface{}) bool {
+			if !yield(i, elem) {
+				return false
+			}
+			i++
+			return true
+		})
+	}
+}
+
+// eachLazyChunk calls f with the elements of l, lazyChunkSize at a time and
+// in order, until f returns false.
+func eachLazyChunk(l Lazy, f func(chunk reflect.Value) bool) {
+	empty := reflect.ValueOf(l.Slice(0, 0))
+	chunk := reflect.MakeSlice(empty.Type(), 0, lazyChunkSize)
+	stopped := false
+
+	l.Iterate(func(elem interface{}) bool {
+		chunk = reflect.Append(chunk, reflect.ValueOf(elem))
+		if chunk.Len() < lazyChunkSize {
+			return true
+		}
+
+		if !f(chunk) {
+			stopped = true
+			return false
+		}
+
+		chunk = reflect.MakeSlice(empty.Type(), 0, lazyChunkSize)
+		return true
+	})
+
+	if !stopped && chunk.Len() > 0 {
+		f(chunk)
+	}
+}
+
+// checkWhereLazy handles the where-matching logic for Lazy sequences. Only
+// the matching elements are kept in memory.
+func (ns *Namespace) checkWhereLazy(l Lazy, kv, mv reflect.Value, path []string, op string) (interface{}, error) {
+	rv := reflect.ValueOf(l.Slice(0, 0))
+
+	var err error
+
+	eachLazyChunk(l, func(chunk reflect.Value) bool {
+		var matches interface{}
+
+		matches, err = ns.checkWhereArray(chunk, kv, mv, path, op)
+		if err != nil {
+			return false
+		}
+
+		rv = reflect.AppendSlice(rv, reflect.ValueOf(matches))
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rv.Interface(), nil
+}
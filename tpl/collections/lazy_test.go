@@ -0,0 +1,146 @@
+// Copyright 2018 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collections
+
+import (
+	"testing"
+
+	"github.com/gohugoio/hugo/deps"
+	"github.com/stretchr/testify/require"
+)
+
+// tstLazy is a Lazy of n ints, 0 to n-1, that counts the elements read.
+type tstLazy struct {
+	n    int
+	read *int
+}
+
+func (l tstLazy) Len() int { return l.n }
+
+func (l tstLazy) Slice(from, to int) interface{} {
+	if to > l.n {
+		to = l.n
+	}
+	s := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		s = append(s, i)
+	}
+	*l.read += len(s)
+	return s
+}
+
+func (l tstLazy) Iterate(yield func(elem interface{}) bool) {
+	for i := 0; i < l.n; i++ {
+		*l.read++
+		if !yield(i) {
+			return
+		}
+	}
+}
+
+func newTstLazy(n int) tstLazy {
+	return tstLazy{n: n, read: new(int)}
+}
+
+func TestLazy(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	ns := New(&deps.Deps{})
+
+	l := newTstLazy(1000)
+
+	n, err := ns.Len(l)
+	assert.NoError(err)
+	assert.Equal(1000, n)
+
+	n, err = ns.Len([]string{"a", "b"})
+	assert.NoError(err)
+	assert.Equal(2, n)
+
+	_, err = ns.Len(nil)
+	assert.Error(err)
+	_, err = ns.Len(t)
+	assert.Error(err)
+
+	first, err := ns.First(3, l)
+	assert.NoError(err)
+	assert.Equal([]int{0, 1, 2}, first)
+	assert.Equal(3, *l.read)
+
+	last, err := ns.Last(2, l)
+	assert.NoError(err)
+	assert.Equal([]int{998, 999}, last)
+
+	after, err := ns.After(997, l)
+	assert.NoError(err)
+	assert.Equal([]int{997, 998, 999}, after)
+
+	l = newTstLazy(lazyChunkSize*2 + 3)
+	var ints []int
+	for i := range ns.LazyRange(l).(func(func(interface{}) bool)) {
+		if i.(int) == 5 {
+			break
+		}
+		ints = append(ints, i.(int))
+	}
+	assert.Equal([]int{0, 1, 2, 3, 4}, ints)
+	assert.Equal(6, *l.read)
+
+	n = 0
+	for i, e := range ns.LazyIndexedRange(l).(func(func(int, interface{}) bool)) {
+		assert.Equal(i, e)
+		n++
+	}
+	assert.Equal(lazyChunkSize*2+3, n)
+
+	assert.Equal([]int{1}, ns.LazyRange([]int{1}))
+	assert.Equal([]int{1}, ns.LazyIndexedRange([]int{1}))
+}
+
+func TestWhereLazy(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	ns := New(&deps.Deps{})
+
+	seq := make([]map[string]int, lazyChunkSize*2+10)
+	for i := range seq {
+		seq[i] = map[string]int{"n": i}
+	}
+
+	result, err := ns.Where(tstMapsLazy(seq), "n", ">=", lazyChunkSize*2)
+	assert.NoError(err)
+	assert.Len(result, 10)
+	assert.Equal(lazyChunkSize*2, result.([]map[string]int)[0]["n"])
+}
+
+type tstMapsLazy []map[string]int
+
+func (l tstMapsLazy) Len() int { return len(l) }
+
+func (l tstMapsLazy) Slice(from, to int) interface{} {
+	if to > len(l) {
+		to = len(l)
+	}
+	return []map[string]int(l[from:to])
+}
+
+func (l tstMapsLazy) Iterate(yield func(elem interface{}) bool) {
+	for _, m := range l {
+		if !yield(m) {
+			return
+		}
+	}
+}
@@ -38,6 +38,10 @@ func (ns *Namespace) Where(seq, key interface{}, args ...interface{}) (interface
 		path = strings.Split(strings.Trim(kv.String(), "."), ".")
 	}
 
+	if l, ok := seq.(Lazy); ok {
+		return ns.checkWhereLazy(l, kv, mv, path, op)
+	}
+
 	switch seqv.Kind() {
 	case reflect.Array, reflect.Slice:
 		return ns.checkWhereArray(seqv, kv, mv, path, op)
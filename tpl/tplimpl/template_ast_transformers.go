@@ -88,9 +88,86 @@ func applyTemplateTransformers(templ *parse.Tree, lookupFn func(name string) *pa
 
 	c.paramsKeysToLower(templ.Root)
 
+	newTemplateContext(lookupFn).iterateRanges(templ.Root)
+
 	return nil
 }
 
+// The template funcs that make range actions iterate lazy collections, such
+// as the store-backed .Site.Pages, instead of reading them into memory.
+const (
+	lazyRangeFunc        = "lazyRange"
+	lazyIndexedRangeFunc = "lazyIndexedRange"
+)
+
+// iterateRanges makes every range action iterate its collection, i.e.
+//
+//	{{ range $i, $p := .Data.Pages }}...{{ else }}...{{ end }}
+//
+// becomes
+//
+//	{{ range $i, $p := .Data.Pages | lazyIndexedRange }}...{{ else }}...{{ end }}
+//
+// The funcs return anything but a lazy collection as is, so the range,
+// and any break or continue in it, works as before.
+func (c *templateContext) iterateRanges(n parse.Node) {
+	switch x := n.(type) {
+	case *parse.ListNode:
+		if x != nil {
+			c.iterateRangesForNodes(x.Nodes...)
+		}
+	case *parse.IfNode:
+		c.iterateRangesForNodes(x.List, x.ElseList)
+	case *parse.WithNode:
+		c.iterateRangesForNodes(x.List, x.ElseList)
+	case *parse.RangeNode:
+		if !isLazyRange(x) {
+			lazyRange(x)
+		}
+		c.iterateRangesForNodes(x.List, x.ElseList)
+	case *parse.TemplateNode:
+		subTempl := c.getIfNotVisited(x.Name)
+		if subTempl != nil {
+			c.iterateRanges(subTempl.Root)
+		}
+	}
+}
+
+func (c *templateContext) iterateRangesForNodes(nodes ...parse.Node) {
+	for _, node := range nodes {
+		c.iterateRanges(node)
+	}
+}
+
+// lazyRange adds the lazy range func that matches the variables of x to
+// its pipeline.
+func lazyRange(x *parse.RangeNode) {
+	fn := lazyRangeFunc
+	if len(x.Pipe.Decl) > 1 {
+		fn = lazyIndexedRangeFunc
+	}
+
+	pos := x.Pipe.Pos
+
+	x.Pipe.Cmds = append(x.Pipe.Cmds, &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Pos:      pos,
+		Args:     []parse.Node{parse.NewIdentifier(fn).SetPos(pos)},
+	})
+}
+
+// isLazyRange is whether lazyRange has been applied to x.
+func isLazyRange(x *parse.RangeNode) bool {
+	cmds := x.Pipe.Cmds
+	if len(cmds) == 0 || len(cmds[len(cmds)-1].Args) != 1 {
+		return false
+	}
+
+	id, ok := cmds[len(cmds)-1].Args[0].(*parse.IdentifierNode)
+
+	return ok && (id.Ident == lazyRangeFunc || id.Ident == lazyIndexedRangeFunc)
+}
+
 // paramsKeysToLower is made purposely non-generic to make it not so tempting
 // to do more of these hard-to-maintain AST transformations.
 func (c *templateContext) paramsKeysToLower(n parse.Node) {
@@ -14,10 +14,14 @@ package tplimpl
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 
 	"html/template"
 
+	"github.com/gohugoio/hugo/deps"
+	"github.com/gohugoio/hugo/tpl/collections"
 	"github.com/stretchr/testify/require"
 )
 
@@ -288,3 +292,69 @@ func TestTransformRecursiveTemplate(t *testing.T) {
 	c.paramsKeysToLower(templ.Tree.Root)
 
 }
+
+// tstInts is a collections.Lazy of the ints 0 to n-1.
+type tstInts int
+
+func (l tstInts) Len() int { return int(l) }
+
+func (l tstInts) Slice(from, to int) interface{} {
+	s := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		s = append(s, i)
+	}
+	return s
+}
+
+func (l tstInts) Iterate(yield func(elem interface{}) bool) {
+	for i := 0; i < int(l); i++ {
+		if !yield(i) {
+			return
+		}
+	}
+}
+
+func TestIterateRanges(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	funcs := map[string]interface{}{
+		"lazyRange":        collections.New(&deps.Deps{}).LazyRange,
+		"lazyIndexedRange": collections.New(&deps.Deps{}).LazyIndexedRange,
+	}
+
+	templ, err := template.New("foo").Funcs(funcs).Parse(`{{ range .Lazy }}{{ . }},{{ end }}|` +
+		`{{ range $i, $e := .Lazy }}{{ if ne $i $e }}BAD{{ end }}{{ end }}|` +
+		`{{ range $i, $e := .Slice }}S{{ $i }}:{{ $e }} {{ end }}|` +
+		`{{ range $k, $v := .Map }}{{ $k }}={{ $v }}{{ end }}|` +
+		`{{ range .None }}x{{ else }}none{{ end }}|` +
+		`{{ range .Empty }}x{{ else }}empty{{ end }}|` +
+		`{{ range .Nil }}x{{ else }}nil{{ end }}|` +
+		`{{ range $i, $e := .Lazy }}{{ if eq $i 3 }}{{ break }}{{ end }}{{ if eq $e 1 }}{{ continue }}{{ end }}{{ $e }}{{ end }}|` +
+		`{{ range $e := .Slice }}{{ break }}{{ end }}`)
+	assert.NoError(err)
+
+	c := newTemplateContext(createParseTreeLookup(templ))
+	c.iterateRanges(templ.Tree.Root)
+	c.iterateRanges(templ.Tree.Root)
+
+	assert.Equal(5, strings.Count(templ.Tree.Root.String(), "| lazyRange"))
+	assert.Equal(4, strings.Count(templ.Tree.Root.String(), "| lazyIndexedRange"))
+
+	var expected bytes.Buffer
+	for i := 0; i < 450; i++ {
+		fmt.Fprintf(&expected, "%d,", i)
+	}
+	expected.WriteString("||S0:5 S1:6 |a=1b=2|none|empty|nil|02|")
+
+	var b bytes.Buffer
+	assert.NoError(templ.Execute(&b, map[string]interface{}{
+		"Lazy":  tstInts(450),
+		"Slice": []int{5, 6},
+		"Map":   map[string]int{"b": 2, "a": 1},
+		"None":  tstInts(0),
+		"Empty": []string{},
+		"Nil":   nil,
+	}))
+	assert.Equal(expected.String(), b.String())
+}